@@ -0,0 +1,110 @@
+package eows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/googollee/go-socket.io"
+)
+
+// maxPendingSize is the max size of output kept while a command is detached
+const maxPendingSize = 1024 * 1024
+
+// DetachedExitKeep is how long a command that exited while detached is kept,
+// with its pending output and exit result, waiting for Reattach
+var DetachedExitKeep = 5 * time.Minute
+
+// Detach detaches command from its transport (done on disconnection),
+// output is then kept (up to maxPendingSize) and replayed on Reattach
+func (e *ExecOverWS) Detach() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.pending.maxSize = maxPendingSize
+	e.detached = true
+}
+
+// Reattach binds a running command to a new socket, registers input events
+// on this socket and replays output emitted while command was detached.
+// When command exited meanwhile, its exit result is reported after output.
+func (e *ExecOverWS) Reattach(so *socketio.Socket, sid string) error {
+	return e.reattach(NewSocketIOTransport(so), so, sid)
+}
+
+// ReattachTransport binds a running command to a new transport (see Reattach)
+func (e *ExecOverWS) ReattachTransport(t Transport) error {
+	return e.reattach(t, nil, t.ID())
+}
+
+func (e *ExecOverWS) reattach(t Transport, so *socketio.Socket, sid string) error {
+	e.lock.Lock()
+	if e.state != StateRunning && e.state != StateExiting && !e.exitKept {
+		e.lock.Unlock()
+		return fmt.Errorf("Command %v not running", e.CmdID)
+	}
+	running := e.state == StateRunning
+
+	// Replay pending output before any new output
+	e.waitOutputTurn(e.outSeq)
+	e.replaying = true
+	e.Transport = t
+	e.SocketIO = so
+	e.Sid = sid
+	pending := e.pending.chunks
+	e.pending.reset()
+	e.detached = false
+	e.lock.Unlock()
+
+	if running {
+		e.registerInputEvents(t, e.inw)
+	}
+	for _, c := range pending {
+		e.callOutputCB(c)
+	}
+
+	e.lock.Lock()
+	e.replaying = false
+	e.outCond.Broadcast()
+	exited := e.exitKept
+	e.exitKept = false
+	if e.keepTimer != nil {
+		e.keepTimer.Stop()
+	}
+	res := e.exitRes
+	e.lock.Unlock()
+
+	if exited {
+		e.callExitCB(res)
+		cmdIDMap.remove(e)
+	}
+	return nil
+}
+
+// unregister removes command from registry once exited, unless it exited
+// while detached: it is then kept for DetachedExitKeep waiting for Reattach
+func (e *ExecOverWS) unregister() {
+	e.lock.Lock()
+	if e.exitKept {
+		e.keepTimer = time.AfterFunc(DetachedExitKeep, e.release)
+		e.lock.Unlock()
+		return
+	}
+	e.lock.Unlock()
+	cmdIDMap.remove(e)
+}
+
+// release drops output and exit result kept for a command that exited while
+// detached, and removes it from registry
+func (e *ExecOverWS) release() {
+	e.lock.Lock()
+	if !e.exitKept {
+		e.lock.Unlock()
+		return
+	}
+	e.exitKept = false
+	e.pending.reset()
+	if e.keepTimer != nil {
+		e.keepTimer.Stop()
+	}
+	e.lock.Unlock()
+	cmdIDMap.remove(e)
+}
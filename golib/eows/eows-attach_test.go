@@ -0,0 +1,150 @@
+// +build !windows
+
+package eows
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newAttachCmd creates a command exchanging input, output and exit events
+// over transport t
+func newAttachCmd(cmdID, script string, t *ChanTransport) *ExecOverWS {
+	e := New("/bin/sh", []string{"-c", script}, nil, t.ID(), cmdID)
+	e.ExecMode = ExecDirect
+	e.OutSplit = SplitLine
+	e.Transport = t
+	e.InputEvent = "in"
+	e.InputCB = func(e *ExecOverWS, stdin string) (string, error) {
+		return stdin, nil
+	}
+	e.OutputEvent = "out"
+	e.ExitEvent = "exit"
+	return e
+}
+
+// waitPending waits until n output chunks are kept for detached command e
+func waitPending(t *testing.T, e *ExecOverWS, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		e.lock.Lock()
+		pending := len(e.pending.chunks)
+		e.lock.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%d output chunks not kept while detached", n)
+}
+
+// received returns output data and exit result sent on transport t
+func received(t *ChanTransport) (string, *ExitResult) {
+	var out []string
+	var res *ExitResult
+	for {
+		select {
+		case m := <-t.Out:
+			switch d := m.Data.(type) {
+			case OutputEvent:
+				out = append(out, d.Data)
+			case ExitResult:
+				res = &d
+			}
+		default:
+			return fmt.Sprint(out), res
+		}
+	}
+}
+
+func TestReattachReplayOrder(t *testing.T) {
+	t1 := NewChanTransport("t1", 100)
+	t2 := NewChanTransport("t2", 100)
+	e := newAttachCmd("attach-order", "read l; seq 1 5; read l; seq 6 8", t1)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	e.Detach()
+	t1.In <- ChanMessage{Event: "in", Data: "go\n"}
+	waitPending(t, e, 5)
+	if err := e.ReattachTransport(t2); err != nil {
+		t.Fatalf("ReattachTransport: %v", err)
+	}
+	t2.In <- ChanMessage{Event: "in", Data: "go\n"}
+	e.Wait()
+
+	if out, res := received(t1); out != "[]" || res != nil {
+		t.Errorf("detached transport received %v, %v", out, res)
+	}
+	out, res := received(t2)
+	if out != "[1 2 3 4 5 6 7 8]" {
+		t.Errorf("output = %v, want [1 2 3 4 5 6 7 8]", out)
+	}
+	if res == nil || res.Code != 0 {
+		t.Errorf("exit result = %v, want code 0", res)
+	}
+	close(t1.In)
+	close(t2.In)
+}
+
+func TestReattachAfterExit(t *testing.T) {
+	t1 := NewChanTransport("t1", 100)
+	t2 := NewChanTransport("t2", 100)
+	e := newAttachCmd("attach-exited", "read l; seq 1 3; exit 2", t1)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	e.Detach()
+	t1.In <- ChanMessage{Event: "in", Data: "go\n"}
+	e.Wait()
+	if GetEows(e.CmdID) != e {
+		t.Fatalf("command exited while detached should be kept")
+	}
+
+	if err := e.ReattachTransport(t2); err != nil {
+		t.Fatalf("ReattachTransport: %v", err)
+	}
+	out, res := received(t2)
+	if out != "[1 2 3]" || res == nil || res.Code != 2 {
+		t.Errorf("received %v, exit %v, want [1 2 3] then code 2", out, res)
+	}
+	if GetEows(e.CmdID) != nil {
+		t.Errorf("command should be unregistered once reattached")
+	}
+	close(t1.In)
+	close(t2.In)
+}
+
+func TestDetachedExitRelease(t *testing.T) {
+	keep := DetachedExitKeep
+	DetachedExitKeep = 50 * time.Millisecond
+	defer func() { DetachedExitKeep = keep }()
+
+	t1 := NewChanTransport("t1", 100)
+	e := newAttachCmd("attach-release", "read l; echo out", t1)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	e.Detach()
+	t1.In <- ChanMessage{Event: "in", Data: "go\n"}
+	e.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for GetEows(e.CmdID) != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if GetEows(e.CmdID) != nil {
+		t.Fatalf("command not released after DetachedExitKeep")
+	}
+	t2 := NewChanTransport("t2", 100)
+	if err := e.ReattachTransport(t2); err == nil {
+		t.Errorf("ReattachTransport of a released command should fail")
+	}
+	if out, res := received(t2); out != "[]" || res != nil {
+		t.Errorf("released command sent %v, %v", out, res)
+	}
+	close(t1.In)
+}
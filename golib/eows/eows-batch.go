@@ -0,0 +1,199 @@
+package eows
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OutputPolicy Type of policy applied when OutputCB can't keep up with output
+type OutputPolicy uint8
+
+const (
+	// OutputBlock Stop reading command output until OutputCB catches up (default)
+	OutputBlock OutputPolicy = iota
+	// OutputDrop Drop output and emit a "N bytes skipped" marker instead
+	OutputDrop
+)
+
+// Default values of output coalescing settings
+const (
+	DefaultOutBatchDelay = 100 * time.Millisecond
+	DefaultOutQueueSize  = 1024 * 1024
+)
+
+// outBatch is a set of consecutive output chunks of the same stream
+type outBatch struct {
+	data   string
+	stderr bool
+	start  time.Time
+}
+
+// outQueue holds output waiting to be sent to OutputCB
+type outQueue struct {
+	sync.Mutex
+	cond    *sync.Cond
+	batches []outBatch
+	size    int
+	skipped [2]int
+	closed  bool
+	done    chan struct{}
+}
+
+// outputCoalesced returns true when output goes through the output queue
+func (e *ExecOverWS) outputCoalesced() bool {
+	return e.OutBatchSize > 0 || e.OutPolicy == OutputDrop
+}
+
+// startOutputQueue creates output queue and starts its sender
+func (e *ExecOverWS) startOutputQueue() {
+	if !e.outputCoalesced() {
+		return
+	}
+	q := &outQueue{done: make(chan struct{})}
+	q.cond = sync.NewCond(q)
+	e.outq = q
+	go e.outputSender()
+}
+
+// stopOutputQueue sends remaining output and stops the sender
+func (e *ExecOverWS) stopOutputQueue() {
+	q := e.outq
+	if q == nil {
+		return
+	}
+	q.Lock()
+	e.queueSkipped(0)
+	e.queueSkipped(1)
+	q.closed = true
+	q.cond.Broadcast()
+	q.Unlock()
+	<-q.done
+}
+
+// queueSkipped queues a marker reporting output dropped from stream
+// (0 for stdout, 1 for stderr), queue lock must be held
+func (e *ExecOverWS) queueSkipped(stream int) {
+	q := e.outq
+	if q.skipped[stream] == 0 {
+		return
+	}
+	marker := fmt.Sprintf("[%d bytes skipped]", q.skipped[stream])
+	if !e.splitLines() {
+		marker = "\n" + marker + "\n"
+	}
+	q.skipped[stream] = 0
+	q.batches = append(q.batches, outBatch{data: marker, stderr: stream == 1})
+	q.size += len(marker)
+}
+
+// queueOutput queues a chunk of stdout (or stderr) for OutputCB, coalescing
+// it with previous chunks of the same stream up to OutBatchSize bytes.
+// In SplitLine mode, coalesced lines are separated by a newline.
+// Output beyond Limits.Output is cut, then the rest is recorded, so that
+// even output dropped later by OutPolicy is in record file.
+func (e *ExecOverWS) queueOutput(data string, stderr bool) {
+	data, ok := e.countOutput(data)
+	if !ok {
+		return
+	}
+	e.recordOutput(data)
+
+	q := e.outq
+	if q == nil {
+		e.emitStream(data, stderr)
+		return
+	}
+
+	maxSize := e.OutQueueSize
+	if maxSize <= 0 {
+		maxSize = DefaultOutQueueSize
+	}
+	stream := 0
+	if stderr {
+		stream = 1
+	}
+
+	q.Lock()
+	if q.closed {
+		// Sender stopped, late output is sent directly
+		q.Unlock()
+		e.emitStream(data, stderr)
+		return
+	}
+	defer q.Unlock()
+
+	if q.size > 0 && q.size+len(data) > maxSize {
+		if e.OutPolicy == OutputDrop {
+			q.skipped[stream] += len(data)
+			return
+		}
+		for q.size > 0 && q.size+len(data) > maxSize && !q.closed {
+			q.cond.Wait()
+		}
+	}
+
+	e.queueSkipped(stream)
+
+	n := len(q.batches)
+	if n > 0 && q.batches[n-1].stderr == stderr &&
+		len(q.batches[n-1].data)+len(data) <= e.OutBatchSize {
+		if e.splitLines() {
+			q.batches[n-1].data += "\n"
+			q.size++
+		}
+		q.batches[n-1].data += data
+	} else {
+		q.batches = append(q.batches, outBatch{data: data, stderr: stderr, start: time.Now()})
+	}
+	q.size += len(data)
+	q.cond.Broadcast()
+}
+
+// outputSender sends queued output to OutputCB, the last batch is sent when
+// full or OutBatchDelay after its first chunk
+func (e *ExecOverWS) outputSender() {
+	q := e.outq
+	latency := e.OutBatchDelay
+	if latency <= 0 {
+		latency = DefaultOutBatchDelay
+	}
+
+	for {
+		q.Lock()
+		for len(q.batches) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.batches) == 0 {
+			q.Unlock()
+			close(q.done)
+			return
+		}
+
+		// Last batch may still grow
+		b := q.batches[0]
+		if len(q.batches) == 1 && !q.closed && len(b.data) < e.OutBatchSize {
+			if wait := latency - time.Since(b.start); wait > 0 {
+				q.Unlock()
+				time.Sleep(wait)
+				continue
+			}
+		}
+
+		q.batches = q.batches[1:]
+		q.size -= len(b.data)
+		q.cond.Broadcast()
+		q.Unlock()
+
+		e.emitStream(b.data, b.stderr)
+	}
+}
+
+// emitStream emits data as stdout or stderr output
+func (e *ExecOverWS) emitStream(data string, stderr bool) {
+	if stderr {
+		e.emitOutput(data, StreamStderr)
+	} else {
+		e.emitOutput(data, StreamStdout)
+	}
+}
@@ -0,0 +1,41 @@
+// +build !windows
+
+package eows
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutputDropMarker(t *testing.T) {
+	var lock sync.Mutex
+	var out []string
+
+	e := New("/bin/sh", []string{"-c", "seq 1 5000"}, nil, "A", "drop")
+	e.ExecMode = ExecDirect
+	e.OutSplit = SplitLine
+	e.OutPolicy = OutputDrop
+	e.OutQueueSize = 100
+	e.OutputCB = func(e *ExecOverWS, stdout, stderr string) {
+		lock.Lock()
+		out = append(out, stdout)
+		lock.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	e.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	all := strings.Join(out, "\n")
+	if !strings.Contains(all, " bytes skipped]") {
+		t.Errorf("no skipped marker in output")
+	}
+	if !strings.HasPrefix(all, "1\n") {
+		t.Errorf("first line missing: %q", all[:10])
+	}
+}
@@ -0,0 +1,74 @@
+package eows
+
+import "time"
+
+// OutputChunk is a piece of output emitted by a command
+type OutputChunk struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Stream StreamID  `json:"stream"`
+	Offset int64     `json:"offset"` // byte offset in its stream
+	Stdout string    `json:"stdout"`
+	Stderr string    `json:"stderr"`
+	System string    `json:"system"` // message emitted by eows
+}
+
+// size returns the size of chunk data
+func (c *OutputChunk) size() int {
+	return len(c.Stdout) + len(c.Stderr) + len(c.System)
+}
+
+// outputRing is a size-bounded list of output chunks, oldest are dropped first
+type outputRing struct {
+	chunks  []OutputChunk
+	size    int
+	maxSize int
+}
+
+// push appends a chunk and drops oldest ones when ring becomes too big
+func (r *outputRing) push(c OutputChunk) {
+	r.chunks = append(r.chunks, c)
+	r.size += c.size()
+	for r.size > r.maxSize && len(r.chunks) > 1 {
+		r.size -= r.chunks[0].size()
+		r.chunks = r.chunks[1:]
+	}
+}
+
+// since returns chunks with a sequence number greater than seq and false
+// when some of the requested chunks have already been dropped
+func (r *outputRing) since(seq uint64) ([]OutputChunk, bool) {
+	res := []OutputChunk{}
+	for _, c := range r.chunks {
+		if c.Seq > seq {
+			res = append(res, c)
+		}
+	}
+	complete := len(r.chunks) > 0 && r.chunks[0].Seq <= seq+1
+	return res, complete
+}
+
+// reset drops all chunks
+func (r *outputRing) reset() {
+	r.chunks = nil
+	r.size = 0
+}
+
+// OutputSince returns output chunks (kept in a buffer of OutBufferSize bytes)
+// with a sequence number greater than seq. Use seq 0 to get whole buffer.
+// Returned bool is false when some chunks have been dropped from buffer.
+func (e *ExecOverWS) OutputSince(seq uint64) ([]OutputChunk, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if seq >= e.outSeq {
+		return []OutputChunk{}, true
+	}
+	return e.history.since(seq)
+}
+
+// OutputSeq returns the sequence number of the last emitted output chunk
+func (e *ExecOverWS) OutputSeq() uint64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.outSeq
+}
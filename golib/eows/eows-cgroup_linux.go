@@ -0,0 +1,79 @@
+// +build go1.20
+
+package eows
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+var cgroupCount uint64
+
+// cgroupCreate creates the cgroup v2 leaf of the command, applies limits on
+// it and sets attr so that command process directly starts in this leaf
+func (e *ExecOverWS) cgroupCreate(attr *syscall.SysProcAttr) error {
+	if e.Limits == nil || e.Limits.Cgroup == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("eows-%d-%d", os.Getpid(), atomic.AddUint64(&cgroupCount, 1))
+	dir := filepath.Join(e.Limits.Cgroup, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("Cgroup create error: %v", err)
+	}
+
+	if e.Limits.Procs > 0 {
+		pids := filepath.Join(dir, "pids.max")
+		if err := ioutil.WriteFile(pids, []byte(strconv.Itoa(e.Limits.Procs)), 0644); err == nil {
+			e.cgroupPids = true
+		} else {
+			e.logDebug("Cgroup pids controller not available, use setrlimit: %v", err)
+		}
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return fmt.Errorf("Cgroup open error: %v", err)
+	}
+
+	e.cgroup = fd
+	attr.UseCgroupFD = true
+	attr.CgroupFD = int(fd.Fd())
+	return nil
+}
+
+// cgroupLimitError returns an error when a cgroup limit has been reached
+func (e *ExecOverWS) cgroupLimitError() error {
+	if e.cgroup == nil || !e.cgroupPids {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(e.cgroup.Name(), "pids.events"))
+	if err != nil {
+		return nil
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(l); len(f) == 2 && f[0] == "max" && f[1] != "0" {
+			return &LimitError{Limit: LimitProcs}
+		}
+	}
+	return nil
+}
+
+// cgroupRemove removes the cgroup leaf of the command
+func (e *ExecOverWS) cgroupRemove() {
+	if e.cgroup == nil {
+		return
+	}
+	e.cgroup.Close()
+	if err := os.Remove(e.cgroup.Name()); err != nil {
+		e.logError("Cgroup remove error: %v", err)
+	}
+	e.cgroup = nil
+}
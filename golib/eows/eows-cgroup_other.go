@@ -0,0 +1,26 @@
+// +build !linux !go1.20
+
+package eows
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// cgroupCreate is only supported on Linux, built with Go 1.20 or later
+// (SysProcAttr.UseCgroupFD)
+func (e *ExecOverWS) cgroupCreate(attr *syscall.SysProcAttr) error {
+	if e.Limits != nil && e.Limits.Cgroup != "" {
+		return fmt.Errorf("Cgroup only supported on Linux with Go 1.20 or later")
+	}
+	return nil
+}
+
+// cgroupLimitError is only supported on Linux
+func (e *ExecOverWS) cgroupLimitError() error {
+	return nil
+}
+
+// cgroupRemove is only supported on Linux
+func (e *ExecOverWS) cgroupRemove() {
+}
@@ -0,0 +1,152 @@
+package eows
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	common "github.com/iotbzh/xds-common/golib"
+)
+
+// EnvPolicy Type of policy used to build command environment
+type EnvPolicy uint8
+
+const (
+	// EnvInheritAll Inherit whole server environment (default)
+	EnvInheritAll EnvPolicy = iota
+	// EnvInheritList Inherit only server variables listed in EnvInherit
+	EnvInheritList
+	// EnvClean Start with an empty environment
+	EnvClean
+)
+
+// environ builds the command environment in the following order:
+//  1. server environment, filtered according to EnvPolicy
+//  2. Env variables, ${VAR} in values resolved from the environment built
+//     so far (see expandEnv) when EnvExpand is set
+//
+// A variable defined several times takes its last value, so Env overrides
+// inherited variables and a later Env entry overrides an earlier one.
+func (e *ExecOverWS) environ() ([]string, error) {
+	vars := []string{}
+
+	switch e.EnvPolicy {
+	case EnvInheritAll:
+		vars = append(vars, os.Environ()...)
+	case EnvInheritList:
+		for _, k := range e.EnvInherit {
+			if v, ok := os.LookupEnv(k); ok {
+				vars = append(vars, k+"="+v)
+			}
+		}
+	case EnvClean:
+	default:
+		return nil, fmt.Errorf("Unsupported environment policy %d", e.EnvPolicy)
+	}
+
+	for _, kv := range e.Env {
+		if e.EnvExpand {
+			k, v := splitEnv(kv)
+			rv, err := expandEnv(v, vars)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot resolve env variable %v: %v", k, err)
+			}
+			kv = k + "=" + rv
+		}
+		vars = append(vars, kv)
+	}
+
+	// Remove duplicates, keeping last value at the place of first definition
+	env := []string{}
+	idx := make(map[string]int)
+	for _, kv := range vars {
+		k, _ := splitEnv(kv)
+		if i, exist := idx[k]; exist {
+			env[i] = kv
+			continue
+		}
+		idx[k] = len(env)
+		env = append(env, kv)
+	}
+
+	return env, nil
+}
+
+// splitEnv splits a KEY=VALUE environment string
+func splitEnv(kv string) (string, string) {
+	if i := strings.Index(kv, "="); i >= 0 {
+		return kv[:i], kv[i+1:]
+	}
+	return kv, ""
+}
+
+// getEnv returns the value of variable key in env
+func getEnv(env []string, key string) string {
+	val, _ := lookupEnv(env, key)
+	return val
+}
+
+// lookupEnv returns the last value of variable key in env and whether it
+// is defined
+func lookupEnv(env []string, key string) (string, bool) {
+	val, found := "", false
+	for _, kv := range env {
+		if k, v := splitEnv(kv); k == key {
+			val, found = v, true
+		}
+	}
+	return val, found
+}
+
+var envVarRe = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// expandEnv resolves ${VAR} in s from env (and not from server environment,
+// so that EnvPolicy is honored), ${EXEPATH} is the server executable path.
+// An undefined variable is an error.
+func expandEnv(s string, env []string) (string, error) {
+	var err error
+	res := envVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := envVarRe.FindStringSubmatch(m)[1]
+		if name == "EXEPATH" {
+			return common.GetExePath()
+		}
+		val, ok := lookupEnv(env, name)
+		if !ok && err == nil {
+			err = fmt.Errorf("%s env variable not defined", name)
+		}
+		return val
+	})
+	return res, err
+}
+
+// lookPath searches cmd in directories of PATH variable of env, relative
+// paths are resolved from command working directory wd
+func lookPath(cmd string, env []string, wd string) (string, error) {
+	if strings.Contains(cmd, string(filepath.Separator)) {
+		return exec.LookPath(joinDir(wd, cmd))
+	}
+	for _, dir := range filepath.SplitList(getEnv(env, "PATH")) {
+		if dir == "" {
+			continue
+		}
+		if p, err := exec.LookPath(joinDir(wd, filepath.Join(dir, cmd))); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("executable file %v not found in PATH", cmd)
+}
+
+// joinDir returns path relative to dir, unless path is absolute. Result is
+// absolute because command is started once in dir.
+func joinDir(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	if p, err := filepath.Abs(filepath.Join(dir, path)); err == nil {
+		return p
+	}
+	return filepath.Join(dir, path)
+}
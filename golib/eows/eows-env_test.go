@@ -0,0 +1,60 @@
+// +build !windows
+
+package eows
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvironExpand(t *testing.T) {
+	os.Setenv("EOWS_TEST_SECRET", "secret")
+	defer os.Unsetenv("EOWS_TEST_SECRET")
+
+	tests := []struct {
+		name    string
+		policy  EnvPolicy
+		inherit []string
+		env     []string
+		want    []string
+		err     bool
+	}{
+		{"previous entries", EnvClean, nil,
+			[]string{"SDK=/opt/sdk", "PATH=/bin", "PATH=${SDK}/bin:${PATH}"},
+			[]string{"SDK=/opt/sdk", "PATH=/opt/sdk/bin:/bin"}, false},
+		{"inherited list", EnvInheritList, []string{"EOWS_TEST_SECRET"},
+			[]string{"V=${EOWS_TEST_SECRET}"},
+			[]string{"EOWS_TEST_SECRET=secret", "V=secret"}, false},
+		{"not inherited", EnvInheritList, []string{"HOME"},
+			[]string{"V=${EOWS_TEST_SECRET}"}, nil, true},
+		{"clean", EnvClean, nil,
+			[]string{"V=${EOWS_TEST_SECRET}"}, nil, true},
+		{"defined later", EnvClean, nil,
+			[]string{"A=${B}", "B=b"}, nil, true},
+		{"no variable", EnvClean, nil,
+			[]string{"A=$B/x", "C=${}"},
+			[]string{"A=$B/x", "C=${}"}, false},
+	}
+	for _, tt := range tests {
+		e := New("true", nil, nil, "A", "env-"+tt.name)
+		e.Env = tt.env
+		e.EnvPolicy = tt.policy
+		e.EnvInherit = tt.inherit
+		e.EnvExpand = true
+		env, err := e.environ()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: environ = %v, want error", tt.name, env)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: environ error: %v", tt.name, err)
+			continue
+		}
+		if got, want := strings.Join(env, " "), strings.Join(tt.want, " "); got != want {
+			t.Errorf("%s: environ = %v, want %v", tt.name, got, want)
+		}
+	}
+}
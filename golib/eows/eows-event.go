@@ -0,0 +1,64 @@
+package eows
+
+import (
+	"fmt"
+	"time"
+)
+
+// StreamID identifies the stream of an output event
+type StreamID uint8
+
+const (
+	// StreamStdout Command standard output
+	StreamStdout StreamID = iota
+	// StreamStderr Command standard error
+	StreamStderr
+	// StreamSystem Messages emitted by eows (eg. timeout)
+	StreamSystem
+)
+
+// String returns a readable name of the stream
+func (s StreamID) String() string {
+	switch s {
+	case StreamStdout:
+		return "stdout"
+	case StreamStderr:
+		return "stderr"
+	case StreamSystem:
+		return "system"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes stream ID as a readable string (eg. in JSON)
+func (s StreamID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// OutputEvent is a chunk of output with its metadata
+type OutputEvent struct {
+	Seq    uint64    `json:"seq"`    // sequence number, common to all streams
+	Time   time.Time `json:"time"`   // time when chunk has been emitted
+	Stream StreamID  `json:"stream"` // stream of the chunk
+	Offset int64     `json:"offset"` // byte offset of Data in emitted stream (line separators included)
+	Data   string    `json:"data"`
+}
+
+// event returns the output event matching a chunk
+func (c *OutputChunk) event() OutputEvent {
+	ev := OutputEvent{Seq: c.Seq, Time: c.Time, Stream: c.Stream, Offset: c.Offset}
+	switch ev.Stream {
+	case StreamStdout:
+		ev.Data = c.Stdout
+	case StreamStderr:
+		ev.Data = c.Stderr
+	case StreamSystem:
+		ev.Data = c.System
+	}
+	return ev
+}
+
+// emitSystem emits a message on system stream, only sent to OutputEventCB
+func (e *ExecOverWS) emitSystem(format string, args ...interface{}) {
+	e.emitChunk(OutputChunk{Stream: StreamSystem, System: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,55 @@
+// +build !windows
+
+package eows
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestOutputEventStreamOffset(t *testing.T) {
+	var lock sync.Mutex
+	var events []OutputEvent
+
+	e := New("/bin/sh", []string{"-c", "echo a >&2; echo >&2; echo b >&2; echo c"}, nil, "A", "event-offset")
+	e.ExecMode = ExecDirect
+	e.OutSplit = SplitLine
+	e.OutputEventCB = func(e *ExecOverWS, ev OutputEvent) {
+		lock.Lock()
+		events = append(events, ev)
+		lock.Unlock()
+	}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	e.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	var stderr []OutputEvent
+	for _, ev := range events {
+		if ev.Stream == StreamStderr {
+			stderr = append(stderr, ev)
+		} else if ev.Stream == StreamStdout && (ev.Data != "c" || ev.Offset != 0) {
+			t.Errorf("stdout event = %+v, want c at offset 0", ev)
+		}
+	}
+	want := []struct {
+		data   string
+		offset int64
+	}{{"a", 0}, {"", 2}, {"b", 3}}
+	if len(stderr) != len(want) {
+		t.Fatalf("stderr events = %+v, want %d events", stderr, len(want))
+	}
+	for i, w := range want {
+		if stderr[i].Data != w.data || stderr[i].Offset != w.offset {
+			t.Errorf("stderr event %d = %q at %d, want %q at %d",
+				i, stderr[i].Data, stderr[i].Offset, w.data, w.offset)
+		}
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Seq != events[i-1].Seq+1 {
+			t.Errorf("events not sequenced: %d after %d", events[i].Seq, events[i-1].Seq)
+		}
+	}
+}
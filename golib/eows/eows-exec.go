@@ -0,0 +1,107 @@
+package eows
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ExecMode Type of method used to execute the command
+type ExecMode uint8
+
+const (
+	// ExecBash Execute /bin/bash -c with Cmd and Args joined as is (default)
+	ExecBash ExecMode = iota
+	// ExecShell Execute Shell with ShellFlags, Cmd is a shell command line
+	// and each of Args is quoted to be passed literally
+	ExecShell
+	// ExecDirect Execute Cmd with Args directly, without any shell.
+	// Exception: when Umask or a setrlimit based limit is set, they are
+	// applied by /bin/sh that then execs Cmd (so /bin/sh must exist and
+	// argv[0] of the command is the resolved path of Cmd)
+	ExecDirect
+)
+
+// Default shell used in ExecShell mode
+const (
+	DefaultShell = "/bin/sh"
+)
+
+var shellSafeRe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s so that it's passed literally to a POSIX shell
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if shellSafeRe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// cmdLine returns the command line as it is interpreted by the selected mode
+func (e *ExecOverWS) cmdLine() string {
+	if e.ExecMode == ExecBash {
+		return strings.TrimSpace(e.Cmd + " " + strings.Join(e.Args, " "))
+	}
+	line := e.Cmd
+	if e.ExecMode == ExecDirect {
+		line = shellQuote(e.Cmd)
+	}
+	for _, a := range e.Args {
+		line += " " + shellQuote(a)
+	}
+	return line
+}
+
+// cmdArgs returns the path of the program to execute and its arguments
+func (e *ExecOverWS) cmdArgs(env []string) (string, []string, error) {
+	path, args, err := e.progArgs(env)
+	if err != nil {
+		return path, args, err
+	}
+
+	// Umask and rlimits are per process and cannot be set by
+	// os.StartProcess, so set them in a shell that next replaces itself by
+	// the command (POSIX exec has no way to keep argv[0])
+	prelude := e.ulimitCmds()
+	if e.Umask != nil {
+		prelude = append(prelude, fmt.Sprintf("umask %04o", *e.Umask&os.ModePerm))
+	}
+	if len(prelude) == 0 {
+		return path, args, nil
+	}
+	script := strings.Join(prelude, " && ") + " && exec \"$@\""
+	return "/bin/sh", append([]string{"/bin/sh", "-c", script, "sh", path}, args[1:]...), nil
+}
+
+// progArgs returns the path of the program selected by ExecMode and its arguments
+func (e *ExecOverWS) progArgs(env []string) (string, []string, error) {
+	switch e.ExecMode {
+	case ExecBash:
+		return "/bin/bash", []string{"/bin/bash", "-c", e.cmdLine()}, nil
+
+	case ExecShell:
+		shell := e.Shell
+		if shell == "" {
+			shell = DefaultShell
+		}
+		flags := e.ShellFlags
+		if len(flags) == 0 {
+			flags = []string{"-c"}
+		}
+		args := append([]string{shell}, flags...)
+		return shell, append(args, e.cmdLine()), nil
+
+	case ExecDirect:
+		path, err := lookPath(e.Cmd, env, e.WorkingDir)
+		if err != nil {
+			return "", nil, fmt.Errorf("Command lookup error: %v", err)
+		}
+		return path, append([]string{e.Cmd}, e.Args...), nil
+	}
+
+	return "", nil, fmt.Errorf("Unsupported exec mode %d", e.ExecMode)
+}
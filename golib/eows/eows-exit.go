@@ -0,0 +1,82 @@
+package eows
+
+import (
+	"os"
+	"time"
+)
+
+// ExitResult describes how a command terminated
+type ExitResult struct {
+	Code       int           `json:"code"`       // exit code, -1 when killed by a signal
+	Signal     string        `json:"signal"`     // name of terminating signal (eg. SIGKILL)
+	CoreDumped bool          `json:"coreDumped"` // a core file has been dumped
+	Timeout    bool          `json:"timeout"`    // killed after CmdExecTimeout or CmdIdleTimeout
+	Canceled   bool          `json:"canceled"`   // context of StartContext done (command killed or not started)
+	Limit      string        `json:"limit"`      // name of the exceeded limit (see LimitError)
+	UserTime   time.Duration `json:"userTime"`   // user CPU time
+	SystemTime time.Duration `json:"systemTime"` // system CPU time
+	MaxRSS     int64         `json:"maxRSS"`     // maximum resident set size in bytes
+	Err        error         `json:"-"`          // start, wait, timeout, cancel or limit error
+	Error      string        `json:"error"`      // message of Err (empty when no error)
+}
+
+// emitExit reports exit result through ExitCB (or transport exit event),
+// called once all output has been emitted. When command is detached, exit
+// result is kept and reported on Reattach.
+func (e *ExecOverWS) emitExit(res ExitResult) {
+	if res.Limit != "" {
+		e.emitSystem("%v", res.Err)
+	}
+
+	if res.Err != nil {
+		res.Error = res.Err.Error()
+	}
+
+	e.lock.Lock()
+	e.exitRes = res
+	e.waitOutputTurn(e.outSeq)
+	if e.detached {
+		e.exitKept = true
+		e.lock.Unlock()
+		return
+	}
+	e.lock.Unlock()
+	e.callExitCB(res)
+}
+
+// callExitCB calls ExitCB, or sends exit event on transport
+func (e *ExecOverWS) callExitCB(res ExitResult) {
+	if e.ExitCB != nil {
+		e.ExitCB(e, res)
+	} else {
+		e.sendEvent(e.ExitEvent, res)
+	}
+}
+
+// exitResult builds the exit result of the command from its process state
+func (e *ExecOverWS) exitResult(sts *os.ProcessState, err error, stopErr error, canceled bool) ExitResult {
+	res := ExitResult{Code: -1, Err: err}
+	if sts != nil {
+		res.Code = sts.ExitCode()
+		res.UserTime = sts.UserTime()
+		res.SystemTime = sts.SystemTime()
+		procExitResult(&res, sts)
+	}
+
+	if lerr := e.exceededLimit(sts); lerr != nil {
+		res.Limit = lerr.(*LimitError).Limit
+		if res.Err == nil {
+			res.Err = lerr
+		}
+	}
+
+	if stopErr != nil {
+		res.Timeout = !canceled
+		res.Canceled = canceled
+		if res.Err == nil {
+			res.Err = stopErr
+		}
+	}
+
+	return res
+}
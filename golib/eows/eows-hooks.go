@@ -0,0 +1,42 @@
+package eows
+
+import "time"
+
+// BeforeStartCB is the hook called before command is spawned, it may modify
+// command (eg. Cmd, Args, Env, WorkingDir) or return an error to abort Start
+type BeforeStartCB func(e *ExecOverWS) error
+
+// AfterStartCB is the hook called once command has been spawned
+type AfterStartCB func(e *ExecOverWS, pid int)
+
+// AfterExitCB is the hook called once command has exited and its exit result
+// has been emitted
+type AfterExitCB func(e *ExecOverWS, res ExitResult, stats OutputStats)
+
+// OutputStats are statistics about output emitted by a command
+type OutputStats struct {
+	Events   uint64        `json:"events"`   // number of output events (see OutputEvent)
+	Stdout   int64         `json:"stdout"`   // bytes emitted on stdout
+	Stderr   int64         `json:"stderr"`   // bytes emitted on stderr
+	Duration time.Duration `json:"duration"` // command execution duration
+}
+
+// OutputStats returns statistics about output emitted so far
+func (e *ExecOverWS) OutputStats() OutputStats {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	st := OutputStats{
+		Events: e.outSeq,
+		Stdout: e.offsets[StreamStdout],
+		Stderr: e.offsets[StreamStderr],
+	}
+	if !e.startTime.IsZero() {
+		end := e.exitTime
+		if end.IsZero() {
+			end = time.Now()
+		}
+		st.Duration = end.Sub(e.startTime)
+	}
+	return st
+}
@@ -3,23 +3,19 @@ package eows
 import (
 	"fmt"
 	"os"
-	"syscall"
 	"time"
 )
 
-type DoneChan struct {
-	status int
-	err    error
-}
-
-// cmdPumpStdin is in charge of receive characters and send them to stdin
-func (e *ExecOverWS) cmdPumpStdin(inw *os.File) {
-
-	done := make(chan DoneChan, 1)
+// registerInputEvents registers stdin, terminal resize and disconnection
+// handlers on transport
+func (e *ExecOverWS) registerInputEvents(t Transport, inw *os.File) {
+	if t == nil {
+		return
+	}
 
 	if e.InputEvent != "" && e.InputCB != nil {
 
-		err := (*e.SocketIO).On(e.InputEvent, func(stdin string) {
+		err := t.OnInput(e.InputEvent, func(stdin string) {
 			in, err := e.InputCB(e, string(stdin))
 			if err != nil {
 				e.logDebug("Error stdin: %s", err.Error())
@@ -35,22 +31,86 @@ func (e *ExecOverWS) cmdPumpStdin(inw *os.File) {
 		}
 	}
 
+	if e.PtyMode && e.ResizeEvent != "" {
+		err := t.OnResize(e.ResizeEvent, func(size TerminalSize) {
+			if err := e.TerminalSetSize(size.Rows, size.Cols); err != nil {
+				e.logError("Error while resizing terminal: %s", err.Error())
+			}
+		})
+		if err != nil {
+			e.logError("Error resize on event: %s", err.Error())
+		}
+	}
+
+	// Keep output of a running command until it is reattached
+	err := t.OnClose(func() {
+		e.lock.Lock()
+		current := e.Transport == t && e.state == StateRunning
+		e.lock.Unlock()
+		if current {
+			e.logDebug("Transport %v closed, detach command %v", t.ID(), e.CmdID)
+			e.Detach()
+		}
+	})
+	if err != nil {
+		e.logError("Error close on event: %s", err.Error())
+	}
+}
+
+// cmdPumpStdin is in charge of receive characters and send them to stdin,
+// it returns the exit result once command has exited
+func (e *ExecOverWS) cmdPumpStdin(inw *os.File) ExitResult {
+
+	// Transport may be changed by Reattach
+	e.lock.Lock()
+	t := e.Transport
+	e.lock.Unlock()
+	e.registerInputEvents(t, inw)
+
 	// Monitor process exit
+	var sts *os.ProcessState
+	var err error
+	exited := make(chan struct{})
 	go func() {
-		status := 0
-		sts, err := e.proc.Wait()
-		if !sts.Success() {
-			s := sts.Sys().(syscall.WaitStatus)
-			status = s.ExitStatus()
-		}
-		done <- DoneChan{status, err}
+		sts, err = e.proc.Wait()
+		close(exited)
 	}()
 
-	// Wait cmd complete
-	select {
-	case dC := <-done:
-		e.ExitCB(e, dC.status, dC.err)
-	case <-time.After(time.Duration(e.CmdExecTimeout) * time.Second):
-		e.ExitCB(e, -999, fmt.Errorf("Exit Timeout for command ID %v", e.CmdID))
+	// Check idle timeout periodically
+	var idleTick <-chan time.Time
+	idleTmo := time.Duration(e.CmdIdleTimeout) * time.Second
+	if idleTmo > 0 {
+		ticker := time.NewTicker(idleTmo / 4)
+		defer ticker.Stop()
+		idleTick = ticker.C
 	}
+	execTmo := time.After(time.Duration(e.CmdExecTimeout) * time.Second)
+
+	// Wait cmd complete, timeout or context cancellation
+	var stopErr error
+	canceled := false
+	for stopErr == nil {
+		select {
+		case <-exited:
+			goto exit
+		case <-execTmo:
+			stopErr = fmt.Errorf("Exit Timeout for command ID %v", e.CmdID)
+		case <-idleTick:
+			if e.idleFor() >= idleTmo {
+				stopErr = fmt.Errorf("Idle Timeout for command ID %v", e.CmdID)
+			}
+		case <-e.ctx.Done():
+			stopErr = fmt.Errorf("Command ID %v canceled: %v", e.CmdID, e.ctx.Err())
+			canceled = true
+		}
+	}
+
+	// Stop command, then wait it for exit result
+	e.logDebug("%v", stopErr)
+	e.emitSystem("%v", stopErr)
+	e.terminate(exited)
+	<-exited
+
+exit:
+	return e.exitResult(sts, err, stopErr, canceled)
 }
@@ -0,0 +1,108 @@
+package eows
+
+import "time"
+
+// CmdState is the lifecycle state of a command
+type CmdState uint8
+
+const (
+	// StateStarting Command registered, process not yet spawned
+	StateStarting CmdState = iota
+	// StateRunning Process is running
+	StateRunning
+	// StateExiting Process exited or timed out, cleanup in progress
+	StateExiting
+	// StateExited Command is terminated
+	StateExited
+)
+
+// String returns a readable name of the state
+func (s CmdState) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateExiting:
+		return "exiting"
+	case StateExited:
+		return "exited"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes state as a readable string (eg. in JSON)
+func (s CmdState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CmdInfo describes a command managed by eows
+type CmdInfo struct {
+	CmdID     string        `json:"cmdID"`
+	Sid       string        `json:"sid"`
+	CmdLine   string        `json:"cmdLine"`
+	Pid       int           `json:"pid"`
+	StartTime time.Time     `json:"startTime"`
+	Elapsed   time.Duration `json:"elapsed"`
+	State     CmdState      `json:"state"`
+}
+
+// Info returns a description of the command and its current state
+func (e *ExecOverWS) Info() CmdInfo {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	elapsed := time.Duration(0)
+	if !e.startTime.IsZero() {
+		if e.state == StateExited {
+			elapsed = e.exitTime.Sub(e.startTime)
+		} else {
+			elapsed = time.Since(e.startTime)
+		}
+	}
+
+	return CmdInfo{
+		CmdID:     e.CmdID,
+		Sid:       e.Sid,
+		CmdLine:   e.cmdLine(),
+		Pid:       e.pid,
+		StartTime: e.startTime,
+		Elapsed:   elapsed,
+		State:     e.state,
+	}
+}
+
+// State returns the current state of the command
+func (e *ExecOverWS) State() CmdState {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.state
+}
+
+// ListEowsInfo returns description of all registered commands
+func ListEowsInfo() []CmdInfo {
+	res := []CmdInfo{}
+	for _, e := range cmdIDMap.list() {
+		res = append(res, e.Info())
+	}
+	return res
+}
+
+// setState changes the state of the command and records related times
+func (e *ExecOverWS) setState(state CmdState) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.state = state
+	switch state {
+	case StateStarting:
+		e.startTime = time.Now()
+	case StateRunning:
+		if e.proc != nil {
+			e.pid = e.proc.Pid
+		}
+		e.lastOutput = time.Now()
+	case StateExited:
+		e.exitTime = time.Now()
+	}
+}
@@ -0,0 +1,61 @@
+package eows
+
+import "time"
+
+// KillStep is a step of the sequence used to stop a command: Signal is sent
+// to command process group, when still alive, Delay after previous step.
+// Exit result is reported once process exits, so last step should kill it.
+type KillStep struct {
+	Signal string        // signal name as accepted by Signal
+	Delay  time.Duration // delay after previous step
+}
+
+// DefaultKillSequence is used when KillSequence is not set: interrupt command
+// and kill it when still alive one second later
+var DefaultKillSequence = []KillStep{
+	{Signal: "SIGINT"},
+	{Signal: "SIGKILL", Delay: time.Second},
+}
+
+// terminate stops command process group applying KillSequence steps until
+// the group is gone or done is closed
+func (e *ExecOverWS) terminate(done <-chan struct{}) {
+	if e.proc == nil {
+		return
+	}
+	seq := e.KillSequence
+	if len(seq) == 0 {
+		seq = DefaultKillSequence
+	}
+
+	for _, step := range seq {
+		if step.Delay > 0 {
+			select {
+			case <-done:
+				return
+			case <-time.After(step.Delay):
+			}
+		}
+
+		sig, err := signalByName(step.Signal)
+		if err != nil {
+			e.logError("Kill sequence signal %v: %v", step.Signal, err)
+			continue
+		}
+		if err := e.signalGroup(sig); err != nil {
+			if processGone(err) {
+				return
+			}
+			e.logError("Kill sequence signal %v: %v", step.Signal, err)
+			continue
+		}
+		e.logDebug("SEND signal %v to proc group %v", sig, e.proc.Pid)
+	}
+}
+
+// idleFor returns the duration since the last output of the command
+func (e *ExecOverWS) idleFor() time.Duration {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return time.Since(e.lastOutput)
+}
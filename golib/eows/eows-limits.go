@@ -0,0 +1,115 @@
+package eows
+
+import (
+	"fmt"
+	"os"
+)
+
+// Limits are resource limits applied to a command, zero means no limit.
+// CPUTime, AddrSpace and OpenFiles are set with setrlimit (so per process),
+// Procs uses pids.max of Cgroup leaf when available, else RLIMIT_NPROC (that
+// counts all processes of the user, including server ones).
+// setrlimit based limits are applied by /bin/sh, even in ExecDirect mode.
+// Only CPUTime, Output and Procs with cgroup are reported as LimitError:
+// reaching AddrSpace, OpenFiles or RLIMIT_NPROC makes a system call of the
+// command fail (ENOMEM, EMFILE, EAGAIN), which is handled by the command.
+// CPUTime breach is detected from the signal that killed command process,
+// so not when the killed process is a child of the command (eg. bash -c).
+type Limits struct {
+	CPUTime   int    // max CPU time in seconds
+	AddrSpace int64  // max address space in bytes
+	OpenFiles int    // max number of open files
+	Procs     int    // max number of processes
+	Output    int64  // max output (stdout + stderr) in bytes
+	Cgroup    string // cgroup v2 directory where a leaf is created for the command (Go 1.20, Linux 5.7)
+}
+
+// LimitError is the error reported through ExitCB when a command has been
+// stopped because it exceeded a limit
+type LimitError struct {
+	Limit string
+}
+
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("Command limit exceeded: %s", le.Limit)
+}
+
+// Limits names used in LimitError
+const (
+	LimitCPUTime = "cpu time"
+	LimitProcs   = "processes"
+	LimitOutput  = "output"
+)
+
+// ulimitCmds returns shell commands that apply setrlimit based limits
+func (e *ExecOverWS) ulimitCmds() []string {
+	cmds := []string{}
+	if e.Limits == nil {
+		return cmds
+	}
+	if e.Limits.CPUTime > 0 {
+		// Soft limit sends SIGXCPU, hard limit one second later SIGKILL
+		cmds = append(cmds, fmt.Sprintf("ulimit -t %d && ulimit -S -t %d",
+			e.Limits.CPUTime+1, e.Limits.CPUTime))
+	}
+	if e.Limits.AddrSpace > 0 {
+		cmds = append(cmds, fmt.Sprintf("ulimit -v %d", e.Limits.AddrSpace/1024))
+	}
+	if e.Limits.OpenFiles > 0 {
+		cmds = append(cmds, fmt.Sprintf("ulimit -n %d", e.Limits.OpenFiles))
+	}
+	if e.Limits.Procs > 0 && !e.cgroupPids {
+		// -u for bash, -p for dash
+		cmds = append(cmds, fmt.Sprintf("{ ulimit -u %d 2>/dev/null || ulimit -p %d; }",
+			e.Limits.Procs, e.Limits.Procs))
+	}
+	return cmds
+}
+
+// countOutput accounts a chunk of output and returns the part of it within
+// output limit. When limit is exceeded, command is killed and the chunk is
+// cut (at a UTF-8 boundary unless output is raw), false is returned when
+// nothing is left to forward
+func (e *ExecOverWS) countOutput(data string) (string, bool) {
+	if e.Limits == nil || e.Limits.Output <= 0 {
+		return data, true
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if le, ok := e.limitErr.(*LimitError); ok && le.Limit == LimitOutput {
+		return "", false
+	}
+	budget := e.Limits.Output - e.outBytes
+	if int64(len(data)) <= budget {
+		e.outBytes += int64(len(data))
+		return data, true
+	}
+
+	if budget < 0 {
+		budget = 0
+	}
+	n := int(budget)
+	if e.RawOutputCB == nil {
+		n = utf8Boundary([]byte(data[:n]))
+	}
+	e.outBytes += int64(n)
+	if e.limitErr == nil {
+		e.limitErr = &LimitError{Limit: LimitOutput}
+		e.logDebug("Command %v exceeds output limit, kill it", e.CmdID)
+		if err := e.signalGroup(os.Kill); err != nil {
+			e.logError("Proc kill:", err)
+		}
+	}
+	return data[:n], n > 0
+}
+
+// exceededLimit returns the error of the limit exceeded by command, if any
+func (e *ExecOverWS) exceededLimit(sts *os.ProcessState) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.limitErr == nil {
+		e.limitErr = e.procLimitError(sts)
+	}
+	return e.limitErr
+}
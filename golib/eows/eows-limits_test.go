@@ -0,0 +1,37 @@
+// +build !windows
+
+package eows
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestOutputLimitCut(t *testing.T) {
+	var lock sync.Mutex
+	var out []string
+
+	e := New("/bin/sh", []string{"-c", "printf 'ab\\n\\303\\251\\303\\251\\n'; sleep 5"}, nil, "A", "limit-output")
+	e.ExecMode = ExecDirect
+	e.OutSplit = SplitLine
+	e.Limits = &Limits{Output: 5}
+	e.OutputCB = func(e *ExecOverWS, stdout, stderr string) {
+		lock.Lock()
+		out = append(out, stdout)
+		lock.Unlock()
+	}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	res := e.Wait()
+
+	if le, ok := res.Err.(*LimitError); !ok || le.Limit != LimitOutput {
+		t.Errorf("exit error = %v, want output limit error", res.Err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	// Budget left for second line is 3 bytes, cut after first character
+	if len(out) != 2 || out[0] != "ab" || out[1] != "é" {
+		t.Errorf("output = %q, want [ab é]", out)
+	}
+}
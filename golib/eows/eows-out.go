@@ -2,35 +2,242 @@ package eows
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // scanBlocks - gain character by character (or as soon as one or more characters are available)
+// An incomplete trailing UTF-8 sequence is kept for next block, so that a
+// character is never split across two blocks
 func scanBlocks(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	n := len(data)
+	if !atEOF {
+		if n = utf8Boundary(data); n == 0 {
+			// Request more data
+			return 0, nil, nil
+		}
+	}
+	return n, data[:n], nil
+}
+
+// scanRaw - gain bytes as soon as they are available
+func scanRaw(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	if atEOF && len(data) == 0 {
 		return 0, nil, nil
 	}
 	return len(data), data, nil
 }
 
-// cmdPumpStdout is in charge to forward stdout in websocket
-func (e *ExecOverWS) cmdPumpStdout(r io.Reader, done chan struct{}) {
+// utf8Boundary returns the length of data without its trailing incomplete
+// UTF-8 sequence, if any
+func utf8Boundary(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
 
-	defer func() {
-	}()
+// Long lines settings used in SplitLine mode
+const (
+	DefaultOutMaxLineSize = bufio.MaxScanTokenSize
+	LongLineMarker        = " [line truncated]"
+)
 
-	sc := bufio.NewScanner(r)
+// scanLinesMax returns a split function working as bufio.ScanLines, except
+// that lines longer than maxLen are split or truncated according to policy
+func scanLinesMax(maxLen int, policy LongLinePolicy) bufio.SplitFunc {
+	truncating := false
+
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		i := bytes.IndexByte(data, '\n')
+		if truncating {
+			// Skip remaining part of truncated line
+			if i >= 0 {
+				truncating = false
+				return i + 1, nil, nil
+			}
+			return len(data), nil, nil
+		}
 
-	// else use default sc.ScanLines
-	if e.OutSplit == SplitChar {
+		// A CRLF line of maxLen characters is maxLen+1 bytes long
+		crlf := len(data) > maxLen && data[maxLen] == '\r'
+		if i >= 0 && (i <= maxLen || i == maxLen+1 && crlf) {
+			return i + 1, bytes.TrimSuffix(data[:i], []byte{'\r'}), nil
+		}
+		if i < 0 && (len(data) <= maxLen || len(data) == maxLen+1 && crlf) {
+			if atEOF {
+				return len(data), bytes.TrimSuffix(data, []byte{'\r'}), nil
+			}
+			// Request more data
+			return 0, nil, nil
+		}
+
+		// Line is too long
+		n := utf8Boundary(data[:maxLen])
+		if n == 0 {
+			n = maxLen
+		}
+		if policy == LongLineTruncate {
+			truncating = true
+			return n, append(data[:n:n], LongLineMarker...), nil
+		}
+		return n, data[:n], nil
+	}
+}
+
+// splitLines returns true when output is tokenized line by line
+func (e *ExecOverWS) splitLines() bool {
+	return e.OutSplit == SplitLine && e.RawOutputCB == nil
+}
+
+// newScanner returns a scanner that tokenizes output according to OutSplit
+// (raw blocks when RawOutputCB is set)
+func (e *ExecOverWS) newScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	if e.RawOutputCB != nil {
+		sc.Split(scanRaw)
+	} else if e.OutSplit == SplitChar {
 		sc.Split(scanBlocks)
+	} else {
+		maxLen := e.OutMaxLineSize
+		if maxLen <= 0 {
+			maxLen = DefaultOutMaxLineSize
+		}
+		sc.Buffer(make([]byte, 4096), maxLen+2)
+		sc.Split(scanLinesMax(maxLen, e.OutLongLine))
+	}
+	return sc
+}
+
+// emitOutput forwards output of stream (stdout or stderr) to OutputCB or
+// keeps it while command is detached
+func (e *ExecOverWS) emitOutput(data string, stream StreamID) {
+	c := OutputChunk{Stream: stream}
+	if stream == StreamStderr {
+		c.Stderr = data
+	} else {
+		c.Stdout = data
+	}
+	e.emitChunk(c)
+}
+
+// emitChunk numbers, timestamps and records a chunk, then forwards it to
+// output callback or keeps it while command is detached
+func (e *ExecOverWS) emitChunk(c OutputChunk) {
+	e.lock.Lock()
+	e.outSeq++
+	c.Seq = e.outSeq
+	c.Time = time.Now()
+	stream := c.Stream
+	c.Offset = e.offsets[stream]
+	e.offsets[stream] += int64(c.size())
+	if stream != StreamSystem && e.splitLines() {
+		e.offsets[stream]++ // line separator
+	}
+	if stream != StreamSystem {
+		e.lastOutput = c.Time
+	}
+	if e.OutBufferSize > 0 {
+		e.history.push(c)
 	}
 
+	// Callbacks are called in sequence order but without any lock held, so
+	// that they may use other methods (eg. Detach)
+	e.waitOutputTurn(c.Seq - 1)
+	if e.detached {
+		e.pending.push(c)
+		e.outputDispatched(c.Seq)
+		e.lock.Unlock()
+		return
+	}
+	e.lock.Unlock()
+
+	e.callOutputCB(c)
+
+	e.lock.Lock()
+	e.outputDispatched(c.Seq)
+	e.lock.Unlock()
+}
+
+// waitOutputTurn waits until output up to seq has been dispatched and no
+// output is being replayed, e.lock must be held
+func (e *ExecOverWS) waitOutputTurn(seq uint64) {
+	if e.outCond == nil {
+		e.outCond = sync.NewCond(&e.lock)
+	}
+	for e.callSeq < seq || e.replaying {
+		e.outCond.Wait()
+	}
+}
+
+// outputDispatched marks output up to seq as dispatched, e.lock must be held
+func (e *ExecOverWS) outputDispatched(seq uint64) {
+	e.callSeq = seq
+	e.outCond.Broadcast()
+}
+
+// callOutputCB calls OutputEventCB when set, else RawOutputCB or OutputCB,
+// else sends output event on transport (system messages are only sent to
+// OutputEventCB and transport)
+func (e *ExecOverWS) callOutputCB(c OutputChunk) {
+	switch {
+	case e.OutputEventCB != nil:
+		e.OutputEventCB(e, c.event())
+	case e.RawOutputCB != nil:
+		if c.Stream != StreamSystem {
+			e.RawOutputCB(e, []byte(c.Stdout), []byte(c.Stderr))
+		}
+	case e.OutputCB != nil:
+		if c.Stream != StreamSystem {
+			e.OutputCB(e, c.Stdout, c.Stderr)
+		}
+	default:
+		e.sendEvent(e.OutputEvent, c.event())
+	}
+}
+
+// sendEvent sends an event on transport when event name is set
+func (e *ExecOverWS) sendEvent(event string, data interface{}) {
+	e.lock.Lock()
+	t := e.Transport
+	e.lock.Unlock()
+	if event == "" || t == nil {
+		return
+	}
+	if err := t.Send(event, data); err != nil {
+		e.logError("Error send %v event: %v", event, err)
+	}
+}
+
+// cmdPumpStdout is in charge to forward stdout in websocket
+func (e *ExecOverWS) cmdPumpStdout(r io.Reader, done chan struct{}) {
+
+	defer func() {
+	}()
+
+	sc := e.newScanner(r)
 	for sc.Scan() {
-		e.OutputCB(e, sc.Text(), "")
+		e.queueOutput(sc.Text(), false)
 	}
-	if sc.Err() != nil && !strings.Contains(sc.Err().Error(), "file already closed") {
+	// Reading pty master returns EIO once command exits
+	if sc.Err() != nil && !strings.Contains(sc.Err().Error(), "file already closed") &&
+		!(e.PtyMode && strings.Contains(sc.Err().Error(), "input/output error")) {
 		e.logError("stdout scan: %v", sc.Err())
 	}
 
@@ -38,21 +245,17 @@ func (e *ExecOverWS) cmdPumpStdout(r io.Reader, done chan struct{}) {
 }
 
 // cmdPumpStderr is in charge to forward stderr in websocket
-func (e *ExecOverWS) cmdPumpStderr(r io.Reader) {
+func (e *ExecOverWS) cmdPumpStderr(r io.Reader, done chan struct{}) {
 
 	defer func() {
 	}()
-	sc := bufio.NewScanner(r)
-
-	// else use default sc.ScanLines
-	if e.OutSplit == SplitChar {
-		sc.Split(scanBlocks)
-	}
-
+	sc := e.newScanner(r)
 	for sc.Scan() {
-		e.OutputCB(e, "", sc.Text())
+		e.queueOutput(sc.Text(), true)
 	}
 	if sc.Err() != nil && !strings.Contains(sc.Err().Error(), "file already closed") {
 		e.logError("stderr scan: %v", sc.Err())
 	}
+
+	close(done)
 }
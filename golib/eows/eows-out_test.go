@@ -0,0 +1,107 @@
+// +build !windows
+
+package eows
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// scanAll returns the tokens of input scanned as OutputCB receives them
+func scanAll(e *ExecOverWS, input string) []string {
+	tokens := []string{}
+	sc := e.newScanner(strings.NewReader(input))
+	for sc.Scan() {
+		tokens = append(tokens, sc.Text())
+	}
+	if sc.Err() != nil {
+		tokens = append(tokens, "error: "+sc.Err().Error())
+	}
+	return tokens
+}
+
+func TestScanLinesMax(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy LongLinePolicy
+		input  string
+		want   []string
+	}{
+		{"short lines", LongLineSplit, "ab\ncd\n", []string{"ab", "cd"}},
+		{"no trailing newline", LongLineSplit, "ab\ncd", []string{"ab", "cd"}},
+		{"empty lines", LongLineSplit, "\n\nab\n", []string{"", "", "ab"}},
+		{"exact length", LongLineSplit, "abcd\nef\n", []string{"abcd", "ef"}},
+		{"exact length at EOF", LongLineSplit, "abcd", []string{"abcd"}},
+		{"split", LongLineSplit, "abcdefghij\nk\n", []string{"abcd", "efgh", "ij", "k"}},
+		{"truncate", LongLineTruncate, "abcdefghij\nk\n", []string{"abcd" + LongLineMarker, "k"}},
+		{"truncate at EOF", LongLineTruncate, "abcdefghij", []string{"abcd" + LongLineMarker}},
+		{"crlf", LongLineSplit, "ab\r\ncd\r\n", []string{"ab", "cd"}},
+		{"crlf exact length", LongLineSplit, "abcd\r\nef\r\n", []string{"abcd", "ef"}},
+		{"crlf exact length at EOF", LongLineSplit, "abcd\r", []string{"abcd"}},
+		{"crlf long", LongLineSplit, "abcde\r\n", []string{"abcd", "e"}},
+		// é is 2 bytes, € is 3 bytes
+		{"multibyte at boundary", LongLineSplit, "abcé\n", []string{"abc", "é"}},
+		{"multibyte exact length", LongLineSplit, "abé\n", []string{"abé"}},
+		{"multibyte truncate", LongLineTruncate, "a€bc\nd\n", []string{"a€" + LongLineMarker, "d"}},
+		{"multibyte split", LongLineSplit, "ab€cd\n", []string{"ab", "€c", "d"}},
+	}
+	for _, tt := range tests {
+		e := &ExecOverWS{OutSplit: SplitLine, OutMaxLineSize: 4, OutLongLine: tt.policy}
+		got := scanAll(e, tt.input)
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%s: tokens = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUtf8Boundary(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"abé", 4},
+		{"ab\xc3", 2},
+		{"a\xe2\x82", 1},
+		{"€", 3},
+		{"\xe2", 0},
+		// Invalid sequences are not held back
+		{"ab\x82", 3},
+		{"a\xff", 2},
+	}
+	for _, tt := range tests {
+		if got := utf8Boundary([]byte(tt.data)); got != tt.want {
+			t.Errorf("utf8Boundary(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestScanBlocks(t *testing.T) {
+	e := &ExecOverWS{OutSplit: SplitChar}
+	// A whole input is read at once, so it is one block
+	if got := scanAll(e, "ab€"); len(got) != 1 || got[0] != "ab€" {
+		t.Errorf("tokens = %q, want [ab€]", got)
+	}
+
+	// Incomplete trailing sequence is kept for next block
+	tests := []struct {
+		data    string
+		atEOF   bool
+		advance int
+		token   string
+	}{
+		{"ab\xe2\x82", false, 2, "ab"},
+		{"\xe2\x82", false, 0, ""},
+		{"\xe2\x82", true, 2, "\xe2\x82"},
+		{"ab€", false, 5, "ab€"},
+	}
+	for _, tt := range tests {
+		advance, token, _ := scanBlocks([]byte(tt.data), tt.atEOF)
+		if advance != tt.advance || string(token) != tt.token {
+			t.Errorf("scanBlocks(%q, %v) = %d, %q, want %d, %q",
+				tt.data, tt.atEOF, advance, token, tt.advance, tt.token)
+		}
+	}
+}
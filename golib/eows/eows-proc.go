@@ -0,0 +1,62 @@
+// +build !windows
+
+package eows
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// sysProcAttr returns attributes used to start command: in its own process
+// group (or session with a controlling terminal in PtyMode) and as
+// Credential user when set
+func (e *ExecOverWS) sysProcAttr() (*syscall.SysProcAttr, error) {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if e.PtyMode {
+		attr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	}
+
+	if e.Credential != nil {
+		attr.Credential = &syscall.Credential{
+			Uid:    e.Credential.Uid,
+			Gid:    e.Credential.Gid,
+			Groups: e.Credential.Groups,
+		}
+	}
+
+	return attr, nil
+}
+
+// procLimitError returns an error when process has been stopped because it
+// exceeded CPUTime (SIGXCPU, or SIGKILL from hard limit) or cgroup pids.max
+func (e *ExecOverWS) procLimitError(sts *os.ProcessState) error {
+	if sts == nil || e.Limits == nil {
+		return nil
+	}
+	if ws, ok := sts.Sys().(syscall.WaitStatus); ok && e.Limits.CPUTime > 0 {
+		cpu := sts.UserTime() + sts.SystemTime()
+		if (ws.Signaled() && ws.Signal() == syscall.SIGXCPU) ||
+			(ws.Signaled() && ws.Signal() == syscall.SIGKILL &&
+				cpu >= time.Duration(e.Limits.CPUTime)*time.Second) {
+			return &LimitError{Limit: LimitCPUTime}
+		}
+	}
+	return e.cgroupLimitError()
+}
+
+// procExitResult fills exit result with terminating signal and memory usage
+func procExitResult(res *ExitResult, sts *os.ProcessState) {
+	if ws, ok := sts.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		res.Signal = signalName(ws.Signal())
+		res.CoreDumped = ws.CoreDump()
+	}
+	if ru, ok := sts.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in kilobytes, except on macOS where it's in bytes
+		res.MaxRSS = int64(ru.Maxrss)
+		if runtime.GOOS != "darwin" {
+			res.MaxRSS *= 1024
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package eows
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// sysProcAttr returns attributes used to start command (none on Windows)
+func (e *ExecOverWS) sysProcAttr() (*syscall.SysProcAttr, error) {
+	if e.Credential != nil {
+		return nil, fmt.Errorf("Credential not supported on Windows")
+	}
+	if e.Limits != nil {
+		return nil, fmt.Errorf("Limits not supported on Windows")
+	}
+	return nil, nil
+}
+
+// procLimitError is not supported on Windows
+func (e *ExecOverWS) procLimitError(sts *os.ProcessState) error {
+	return nil
+}
+
+// procExitResult has nothing to add on Windows
+func procExitResult(res *ExitResult, sts *os.ProcessState) {
+}
@@ -0,0 +1,72 @@
+// +build !windows
+
+package eows
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/kr/pty"
+)
+
+// startPty starts the command attached to a new pseudo-terminal and returns
+// the pty master used to both read output and write input
+func (e *ExecOverWS) startPty(name string, args []string, attr *os.ProcAttr) (*os.File, error) {
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Pty open error: %v", err)
+	}
+
+	// Slave side is only needed by the child, close it in parent so that
+	// reading master returns an error as soon as the command exits
+	defer pts.Close()
+
+	e.lock.Lock()
+	size := e.ptySize
+	e.lock.Unlock()
+	if size.Rows != 0 && size.Cols != 0 {
+		ws := &pty.Winsize{Rows: size.Rows, Cols: size.Cols}
+		if err := pty.Setsize(pts, ws); err != nil {
+			e.logError("Pty set size error: %v", err)
+		}
+	}
+
+	attr.Files = []*os.File{pts, pts, pts}
+	e.proc, err = os.StartProcess(name, args, attr)
+	if err != nil {
+		ptm.Close()
+		return nil, fmt.Errorf("Process start error: %v", err)
+	}
+
+	e.ptm = ptm
+	return ptm, nil
+}
+
+// TerminalSetSize sets the window size of the pseudo-terminal and notifies
+// the command with SIGWINCH. Size set before Start is applied at startup,
+// an error is returned once started while command is not running.
+func (e *ExecOverWS) TerminalSetSize(rows, cols uint16) error {
+	if !e.PtyMode {
+		return fmt.Errorf("Terminal resize only supported in PtyMode")
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.started {
+		e.ptySize = TerminalSize{Rows: rows, Cols: cols}
+		return nil
+	}
+	if e.state != StateRunning {
+		return fmt.Errorf("Command %v not running", e.CmdID)
+	}
+	e.ptySize = TerminalSize{Rows: rows, Cols: cols}
+
+	if err := pty.Setsize(e.ptm, &pty.Winsize{Rows: rows, Cols: cols}); err != nil {
+		return fmt.Errorf("Terminal resize error: %v", err)
+	}
+	e.recordResize(rows, cols)
+
+	e.logDebug("SEND signal SIGWINCH to proc %v", e.proc.Pid)
+	return syscall.Kill(-e.proc.Pid, syscall.SIGWINCH)
+}
@@ -0,0 +1,57 @@
+// +build !windows
+
+package eows
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTerminalSetSize(t *testing.T) {
+	var lock sync.Mutex
+	var out []string
+
+	e := New("/bin/sh", []string{"-c", "stty size; read l; stty size"}, nil, "A", "pty-size")
+	e.ExecMode = ExecDirect
+	e.OutSplit = SplitLine
+	e.PtyMode = true
+	e.OutputCB = func(e *ExecOverWS, stdout, stderr string) {
+		lock.Lock()
+		out = append(out, strings.TrimSpace(stdout))
+		lock.Unlock()
+	}
+	if err := e.TerminalSetSize(24, 80); err != nil {
+		t.Fatalf("TerminalSetSize before Start: %v", err)
+	}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// Wait for first size before resizing
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		lock.Lock()
+		n := len(out)
+		lock.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := e.TerminalSetSize(40, 100); err != nil {
+		t.Errorf("TerminalSetSize while running: %v", err)
+	}
+	e.ptm.Write([]byte("\n"))
+	e.Wait()
+
+	if err := e.TerminalSetSize(50, 120); err == nil {
+		t.Errorf("TerminalSetSize of an exited command should fail")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(out) < 2 || out[0] != "24 80" || out[len(out)-1] != "40 100" {
+		t.Errorf("output = %q, want sizes 24 80 then 40 100", out)
+	}
+}
@@ -0,0 +1,16 @@
+package eows
+
+import (
+	"fmt"
+	"os"
+)
+
+// startPty is not supported on Windows
+func (e *ExecOverWS) startPty(name string, args []string, attr *os.ProcAttr) (*os.File, error) {
+	return nil, fmt.Errorf("Pty mode not supported on Windows")
+}
+
+// TerminalSetSize is not supported on Windows
+func (e *ExecOverWS) TerminalSetSize(rows, cols uint16) error {
+	return fmt.Errorf("Pty mode not supported on Windows")
+}
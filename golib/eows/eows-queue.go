@@ -0,0 +1,235 @@
+package eows
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// QueuePosition is the event sent to client while its command is queued
+type QueuePosition struct {
+	CmdID    string `json:"cmdID"`
+	Position int    `json:"position"` // position in queue, 0 is next command started
+	Length   int    `json:"length"`   // number of queued commands
+}
+
+// Scheduler queues commands and starts them while concurrency limits allow
+// it: commands with higher priority first, then in submission order.
+type Scheduler struct {
+	MaxRunning    int    // max number of running commands (0 for no limit)
+	MaxRunningSid int    // max number of running commands per Sid (0 for no limit)
+	QueueEvent    string // transport event used to send QueuePosition (empty to disable)
+
+	lock    sync.Mutex
+	queue   []*queuedCmd
+	running int
+	sids    map[string]int
+}
+
+// queuedCmd is a command waiting in scheduler queue
+type queuedCmd struct {
+	e        *ExecOverWS
+	ctx      context.Context
+	priority int
+	sid      string
+	removed  chan struct{} // closed once command leaves queue (started or canceled)
+	position chan QueuePosition
+}
+
+// NewScheduler creates a new command scheduler
+func NewScheduler(maxRunning, maxRunningSid int) *Scheduler {
+	return &Scheduler{
+		MaxRunning:    maxRunning,
+		MaxRunningSid: maxRunningSid,
+		sids:          make(map[string]int),
+	}
+}
+
+// Submit queues a command that is started later with StartContext(ctx).
+// A queued command is removed on Cancel or when ctx is done, it then exits
+// (see ExitCB and Wait) with Canceled set in its exit result.
+func (s *Scheduler) Submit(ctx context.Context, e *ExecOverWS, priority int) error {
+	if e.Transport == nil && e.SocketIO != nil {
+		e.Transport = NewSocketIOTransport(e.SocketIO)
+	}
+	q := &queuedCmd{e: e, ctx: ctx, priority: priority, sid: e.Sid,
+		removed: make(chan struct{}), position: make(chan QueuePosition, 1)}
+
+	s.lock.Lock()
+	for _, qc := range s.queue {
+		if qc.e.CmdID == e.CmdID {
+			s.lock.Unlock()
+			return fmt.Errorf("Command ID %v already queued", e.CmdID)
+		}
+	}
+	// Insert after commands with same or higher priority
+	i := len(s.queue)
+	for i > 0 && s.queue[i-1].priority < priority {
+		i--
+	}
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = q
+	s.lock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.cancel(q, fmt.Errorf("Queued command ID %v canceled: %v", e.CmdID, ctx.Err()))
+		case <-q.removed:
+		}
+	}()
+	if s.QueueEvent != "" {
+		go s.notify(q)
+	}
+
+	s.dispatch()
+	return nil
+}
+
+// Cancel removes a command from queue
+func (s *Scheduler) Cancel(cmdID string) error {
+	s.lock.Lock()
+	var q *queuedCmd
+	for _, qc := range s.queue {
+		if qc.e.CmdID == cmdID {
+			q = qc
+		}
+	}
+	s.lock.Unlock()
+
+	if q == nil || !s.cancel(q, fmt.Errorf("Queued command ID %v canceled", cmdID)) {
+		return fmt.Errorf("Command ID %v not queued", cmdID)
+	}
+	return nil
+}
+
+// Position returns the position of a command in queue, -1 if not queued
+func (s *Scheduler) Position(cmdID string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, q := range s.queue {
+		if q.e.CmdID == cmdID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len returns the number of queued commands
+func (s *Scheduler) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.queue)
+}
+
+// cancel removes a command from queue and reports its exit, returns false
+// when command is no more queued
+func (s *Scheduler) cancel(q *queuedCmd, err error) bool {
+	if !s.remove(q) {
+		return false
+	}
+	q.e.logDebug("%v", err)
+	// Command may have been started outside of scheduler
+	if q.e.begin() == nil {
+		q.e.emitExit(ExitResult{Code: -1, Canceled: true, Err: err})
+		q.e.setState(StateExited)
+		close(q.e.done)
+	}
+
+	s.dispatch()
+	return true
+}
+
+// remove removes a command from queue
+func (s *Scheduler) remove(q *queuedCmd) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, qc := range s.queue {
+		if qc == q {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			close(q.removed)
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch starts queued commands allowed by limits, then updates queue
+// positions sent to clients
+func (s *Scheduler) dispatch() {
+	var start []*queuedCmd
+
+	s.lock.Lock()
+	if s.sids == nil {
+		s.sids = make(map[string]int)
+	}
+	for i := 0; i < len(s.queue); {
+		if s.MaxRunning > 0 && s.running >= s.MaxRunning {
+			break
+		}
+		q := s.queue[i]
+		// Commands of a Sid at its limit don't block other Sids
+		if s.MaxRunningSid > 0 && s.sids[q.sid] >= s.MaxRunningSid {
+			i++
+			continue
+		}
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		close(q.removed)
+		s.running++
+		s.sids[q.sid]++
+		start = append(start, q)
+	}
+	if s.QueueEvent != "" {
+		for i, q := range s.queue {
+			q.setPosition(QueuePosition{CmdID: q.e.CmdID, Position: i, Length: len(s.queue)})
+		}
+	}
+	s.lock.Unlock()
+
+	for _, q := range start {
+		go s.run(q)
+	}
+}
+
+// setPosition sets the position to send to client, replacing the one not yet
+// sent if any, scheduler lock must be held
+func (q *queuedCmd) setPosition(pos QueuePosition) {
+	select {
+	case <-q.position:
+	default:
+	}
+	q.position <- pos
+}
+
+// notify sends positions of a queued command to its client until command
+// leaves queue, so that a slow client never blocks the scheduler
+func (s *Scheduler) notify(q *queuedCmd) {
+	for {
+		select {
+		case pos := <-q.position:
+			q.e.sendEvent(s.QueueEvent, pos)
+		case <-q.removed:
+			return
+		}
+	}
+}
+
+// run starts a dequeued command and releases its slot once it has exited
+func (s *Scheduler) run(q *queuedCmd) {
+	if err := q.e.StartContext(q.ctx); err != nil {
+		q.e.logError("Queued command start error: %v", err)
+		q.e.emitExit(q.e.Wait())
+	} else {
+		q.e.Wait()
+	}
+
+	s.lock.Lock()
+	s.running--
+	if s.sids[q.sid]--; s.sids[q.sid] <= 0 {
+		delete(s.sids, q.sid)
+	}
+	s.lock.Unlock()
+
+	s.dispatch()
+}
@@ -0,0 +1,227 @@
+// +build !windows
+
+package eows
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queueTest records start order of commands submitted to a scheduler
+type queueTest struct {
+	sync.Mutex
+	started []string
+	exited  []string
+}
+
+func (qt *queueTest) newCmd(cmdID, sid, script string) *ExecOverWS {
+	e := New("/bin/sh", []string{"-c", script}, nil, sid, cmdID)
+	e.ExecMode = ExecDirect
+	e.AfterStart = func(e *ExecOverWS, pid int) {
+		qt.Lock()
+		qt.started = append(qt.started, e.CmdID)
+		qt.Unlock()
+	}
+	e.ExitCB = func(e *ExecOverWS, res ExitResult) {
+		qt.Lock()
+		qt.exited = append(qt.exited, e.CmdID)
+		qt.Unlock()
+	}
+	return e
+}
+
+func (qt *queueTest) startOrder() string {
+	qt.Lock()
+	defer qt.Unlock()
+	return fmt.Sprint(qt.started)
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	qt := &queueTest{}
+	s := NewScheduler(1, 0)
+	ctx := context.Background()
+
+	block := qt.newCmd("prio-block", "A", "sleep 0.3")
+	low := qt.newCmd("prio-low", "A", "true")
+	high1 := qt.newCmd("prio-high1", "A", "true")
+	high2 := qt.newCmd("prio-high2", "A", "true")
+	for _, sub := range []struct {
+		e    *ExecOverWS
+		prio int
+	}{{block, 0}, {low, 0}, {high1, 5}, {high2, 5}} {
+		if err := s.Submit(ctx, sub.e, sub.prio); err != nil {
+			t.Fatalf("Submit %v: %v", sub.e.CmdID, err)
+		}
+	}
+	if pos := s.Position("prio-low"); pos != 2 {
+		t.Errorf("Position of prio-low = %d, want 2", pos)
+	}
+
+	for _, e := range []*ExecOverWS{block, low, high1, high2} {
+		e.Wait()
+	}
+	want := "[prio-block prio-high1 prio-high2 prio-low]"
+	if got := qt.startOrder(); got != want {
+		t.Errorf("start order = %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerSidLimit(t *testing.T) {
+	qt := &queueTest{}
+	s := NewScheduler(0, 1)
+	ctx := context.Background()
+
+	a1 := qt.newCmd("sid-a1", "A", "sleep 0.3")
+	a2 := qt.newCmd("sid-a2", "A", "true")
+	b1 := qt.newCmd("sid-b1", "B", "true")
+	for _, e := range []*ExecOverWS{a1, a2, b1} {
+		if err := s.Submit(ctx, e, 0); err != nil {
+			t.Fatalf("Submit %v: %v", e.CmdID, err)
+		}
+	}
+
+	// Command of another Sid is not blocked by sid-a2
+	b1.Wait()
+	if s.Position("sid-a2") != 0 {
+		t.Errorf("sid-a2 should be queued while sid-a1 runs")
+	}
+	if st := a1.State(); st != StateRunning {
+		t.Errorf("sid-a1 state = %v, want running", st)
+	}
+
+	a2.Wait()
+	a1.Wait()
+	qt.Lock()
+	defer qt.Unlock()
+	if len(qt.exited) != 3 || qt.exited[0] != "sid-b1" || qt.exited[1] != "sid-a1" {
+		t.Errorf("exit order = %v, want [sid-b1 sid-a1 sid-a2]", qt.exited)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	qt := &queueTest{}
+	s := NewScheduler(1, 0)
+
+	block := qt.newCmd("cancel-block", "A", "sleep 0.3")
+	queued := qt.newCmd("cancel-queued", "A", "true")
+	timed := qt.newCmd("cancel-ctx", "A", "true")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tctx, tcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer tcancel()
+
+	s.Submit(context.Background(), block, 0)
+	s.Submit(ctx, queued, 0)
+	s.Submit(tctx, timed, 0)
+
+	if err := s.Cancel("cancel-queued"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if err := s.Cancel("cancel-queued"); err == nil {
+		t.Errorf("Cancel of a command no more queued should fail")
+	}
+	if err := s.Cancel("cancel-block"); err == nil {
+		t.Errorf("Cancel of a running command should fail")
+	}
+
+	for _, e := range []*ExecOverWS{queued, timed} {
+		res := e.Wait()
+		if !res.Canceled || res.Err == nil || res.Error == "" {
+			t.Errorf("%v exit result = %+v, want canceled with error", e.CmdID, res)
+		}
+		if err := e.Start(); err == nil {
+			t.Errorf("Start of canceled %v should fail", e.CmdID)
+		}
+	}
+	block.Wait()
+
+	if got := qt.startOrder(); got != "[cancel-block]" {
+		t.Errorf("started = %v, want [cancel-block]", got)
+	}
+	if s.Len() != 0 {
+		t.Errorf("queue length = %d, want 0", s.Len())
+	}
+}
+
+func TestSchedulerQueueEvent(t *testing.T) {
+	qt := &queueTest{}
+	s := NewScheduler(1, 0)
+	s.QueueEvent = "queue"
+
+	block := qt.newCmd("event-block", "A", "sleep 0.2")
+	queued := qt.newCmd("event-queued", "B", "true")
+	tr := NewChanTransport("B", 10)
+	queued.Transport = tr
+
+	s.Submit(context.Background(), block, 0)
+	s.Submit(context.Background(), queued, 0)
+	queued.Wait()
+
+	select {
+	case m := <-tr.Out:
+		pos, ok := m.Data.(QueuePosition)
+		if m.Event != "queue" || !ok || pos.CmdID != "event-queued" || pos.Position != 0 {
+			t.Errorf("queue event = %+v", m)
+		}
+	default:
+		t.Errorf("no queue position event sent")
+	}
+}
+
+func TestSchedulerCancelReleasesWatcher(t *testing.T) {
+	qt := &queueTest{}
+	s := NewScheduler(1, 0)
+
+	block := qt.newCmd("watch-block", "A", "sleep 0.2")
+	s.Submit(context.Background(), block, 0)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		e := qt.newCmd(fmt.Sprintf("watch-%d", i), "A", "true")
+		s.Submit(context.Background(), e, 0)
+		s.Cancel(e.CmdID)
+	}
+	block.Wait()
+
+	// Watchers of canceled commands exit even if their context never ends
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left, want at most %d", n, before)
+	}
+}
+
+func TestSchedulerQueueEventStalled(t *testing.T) {
+	qt := &queueTest{}
+	s := NewScheduler(1, 0)
+	s.QueueEvent = "queue"
+
+	block := qt.newCmd("stall-block", "A", "sleep 0.2")
+	s.Submit(context.Background(), block, 0)
+
+	// Nobody reads Out of this transport, Send blocks
+	tr := NewChanTransport("B", 0)
+	stalled := qt.newCmd("stall-queued", "B", "true")
+	stalled.Transport = tr
+	s.Submit(context.Background(), stalled, 0)
+
+	done := make(chan struct{})
+	go func() {
+		other := qt.newCmd("stall-other", "C", "true")
+		s.Submit(context.Background(), other, 0)
+		other.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("scheduler blocked by a stalled transport")
+	}
+	stalled.Wait()
+	close(tr.In)
+}
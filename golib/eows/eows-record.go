@@ -0,0 +1,210 @@
+package eows
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordFormat Type of file format used to record output
+type RecordFormat uint8
+
+const (
+	// RecordPlain Record stdout and stderr as plain text
+	RecordPlain RecordFormat = iota
+	// RecordAsciicast Record output and resize events in asciicast v2 format
+	// (see https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md)
+	RecordAsciicast
+)
+
+// Record describes how command output is recorded in a file
+type Record struct {
+	Path     string       // record file path
+	Format   RecordFormat // file format (default RecordPlain)
+	MaxSize  int64        // max file size in bytes (0 for no limit)
+	MaxFiles int          // rotated files kept when MaxSize is reached (0 to stop recording)
+}
+
+// Default terminal size written in asciicast header when unknown
+const (
+	DefaultRecordRows = 24
+	DefaultRecordCols = 80
+)
+
+// recorder writes command output into record file
+type recorder struct {
+	sync.Mutex
+	conf  Record
+	file  *os.File
+	size  int64
+	start time.Time
+	size0 TerminalSize
+	lines bool
+	e     *ExecOverWS
+}
+
+// recordOpen creates record file when Record is set
+func (e *ExecOverWS) recordOpen() error {
+	if e.Record == nil {
+		return nil
+	}
+	if e.Record.Path == "" {
+		return fmt.Errorf("Record path not set")
+	}
+	r := &recorder{conf: *e.Record, size0: e.ptySize, lines: e.splitLines(), e: e}
+	if r.size0.Rows == 0 || r.size0.Cols == 0 {
+		r.size0 = TerminalSize{Rows: DefaultRecordRows, Cols: DefaultRecordCols}
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	e.rec = r
+	return nil
+}
+
+// recordClose closes record file
+func (e *ExecOverWS) recordClose() {
+	if e.rec == nil {
+		return
+	}
+	e.rec.Lock()
+	defer e.rec.Unlock()
+	e.rec.close()
+}
+
+// open creates a new record file and writes its header
+func (r *recorder) open() error {
+	f, err := os.OpenFile(r.conf.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Record file open error: %v", err)
+	}
+	r.file = f
+	r.size = 0
+	r.start = time.Now()
+
+	if r.conf.Format != RecordAsciicast {
+		return nil
+	}
+	hdr := map[string]interface{}{
+		"version":   2,
+		"width":     r.size0.Cols,
+		"height":    r.size0.Rows,
+		"timestamp": r.start.Unix(),
+		"command":   r.e.cmdLine(),
+	}
+	b, err := json.Marshal(hdr)
+	if err != nil {
+		return err
+	}
+	return r.write(append(b, '\n'))
+}
+
+// close closes current record file
+func (r *recorder) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// rotate renames record files (Path to Path.1, Path.1 to Path.2...) and
+// opens a new one, or stops recording when MaxFiles is not set
+func (r *recorder) rotate() error {
+	r.close()
+	if r.conf.MaxFiles <= 0 {
+		return fmt.Errorf("Record size limit reached")
+	}
+	for i := r.conf.MaxFiles - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.conf.Path, i), fmt.Sprintf("%s.%d", r.conf.Path, i+1))
+	}
+	if err := os.Rename(r.conf.Path, r.conf.Path+".1"); err != nil {
+		return fmt.Errorf("Record file rotate error: %v", err)
+	}
+	return r.open()
+}
+
+// write writes data into record file
+func (r *recorder) write(data []byte) error {
+	n, err := r.file.Write(data)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("Record file write error: %v", err)
+	}
+	return nil
+}
+
+// encode returns an output (o) or resize (r) event in record file format,
+// nil when event is not recorded
+func (r *recorder) encode(t time.Time, code string, data string) []byte {
+	if r.conf.Format != RecordAsciicast {
+		if code != "o" {
+			return nil
+		}
+		if r.lines {
+			data += "\n"
+		}
+		return []byte(data)
+	}
+
+	if code == "o" && r.lines {
+		data = strings.Replace(data, "\n", "\r\n", -1) + "\r\n"
+	}
+	tm := t.Sub(r.start).Seconds()
+	if tm < 0 {
+		tm = 0
+	}
+	b, err := json.Marshal([]interface{}{tm, code, data})
+	if err != nil {
+		return nil
+	}
+	return append(b, '\n')
+}
+
+// record writes an event in record file, rotating it when MaxSize is reached
+func (r *recorder) record(t time.Time, code string, data string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.file == nil {
+		return
+	}
+
+	b := r.encode(t, code, data)
+	if b == nil {
+		return
+	}
+	if r.conf.MaxSize > 0 && r.size > 0 && r.size+int64(len(b)) > r.conf.MaxSize {
+		if err := r.rotate(); err != nil {
+			r.e.logError("%v", err)
+			r.close()
+			return
+		}
+		// Timing is relative to the header of the new file
+		b = r.encode(t, code, data)
+	}
+	if err := r.write(b); err != nil {
+		r.e.logError("%v", err)
+		r.close()
+	}
+}
+
+// recordOutput records a chunk of stdout or stderr
+func (e *ExecOverWS) recordOutput(data string) {
+	if e.rec == nil {
+		return
+	}
+	e.rec.record(time.Now(), "o", data)
+}
+
+// recordResize records a terminal resize event
+func (e *ExecOverWS) recordResize(rows, cols uint16) {
+	if e.rec == nil {
+		return
+	}
+	e.rec.Lock()
+	e.rec.size0 = TerminalSize{Rows: rows, Cols: cols}
+	e.rec.Unlock()
+	e.rec.record(time.Now(), "r", fmt.Sprintf("%dx%d", cols, rows))
+}
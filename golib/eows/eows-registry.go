@@ -0,0 +1,72 @@
+package eows
+
+import (
+	"fmt"
+	"sync"
+)
+
+// cmdRegistry is a concurrency-safe set of ExecOverWS indexed by command ID
+type cmdRegistry struct {
+	sync.RWMutex
+	cmds map[string]*ExecOverWS
+}
+
+var cmdIDMap = &cmdRegistry{cmds: make(map[string]*ExecOverWS)}
+
+// add registers a command, an already used command ID is rejected
+func (r *cmdRegistry) add(e *ExecOverWS) error {
+	r.Lock()
+	defer r.Unlock()
+	if _, exist := r.cmds[e.CmdID]; exist {
+		return fmt.Errorf("Command ID %v already in use", e.CmdID)
+	}
+	r.cmds[e.CmdID] = e
+	return nil
+}
+
+// get returns the command registered with cmdID or nil
+func (r *cmdRegistry) get(cmdID string) *ExecOverWS {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cmds[cmdID]
+}
+
+// list returns a snapshot of all registered commands
+func (r *cmdRegistry) list() []*ExecOverWS {
+	r.RLock()
+	defer r.RUnlock()
+	res := make([]*ExecOverWS, 0, len(r.cmds))
+	for _, e := range r.cmds {
+		res = append(res, e)
+	}
+	return res
+}
+
+// remove unregisters a command, only when cmdID still references e
+func (r *cmdRegistry) remove(e *ExecOverWS) {
+	r.Lock()
+	defer r.Unlock()
+	if r.cmds[e.CmdID] == e {
+		delete(r.cmds, e.CmdID)
+	}
+}
+
+// GetEows gets ExecOverWS object from command ID
+func GetEows(cmdID string) *ExecOverWS {
+	return cmdIDMap.get(cmdID)
+}
+
+// ListEows returns all registered (started and not yet exited) commands
+func ListEows() []*ExecOverWS {
+	return cmdIDMap.list()
+}
+
+// ForEachEows calls f for each registered command until f returns false.
+// Iteration works on a snapshot, so f can safely start or signal commands.
+func ForEachEows(f func(e *ExecOverWS) bool) {
+	for _, e := range cmdIDMap.list() {
+		if !f(e) {
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package eows
+
+import "time"
+
+// KillSid terminates all commands attached to socket ID sid, typically when
+// socket is disconnected. When grace is not zero, commands are detached and
+// killed after this delay, unless they have been reattached meanwhile.
+func KillSid(sid string, grace time.Duration) {
+	if grace <= 0 {
+		killSid(sid)
+		return
+	}
+
+	// Keep output until commands are reattached or killed
+	for _, e := range cmdIDMap.list() {
+		if e.Info().Sid == sid {
+			e.Detach()
+		}
+	}
+	time.AfterFunc(grace, func() { killSid(sid) })
+}
+
+// killSid terminates all commands currently attached to socket ID sid, and
+// releases the ones that exited while detached
+func killSid(sid string) {
+	for _, e := range cmdIDMap.list() {
+		if e.Info().Sid == sid {
+			e.release()
+			go e.kill()
+		}
+	}
+}
+
+// kill terminates the command process group using KillSequence. A command
+// still starting is killed as soon as it is running (see killRequested).
+func (e *ExecOverWS) kill() {
+	e.lock.Lock()
+	state := e.state
+	if state == StateStarting {
+		e.killing = true
+	}
+	e.lock.Unlock()
+	if state != StateRunning {
+		return
+	}
+
+	e.logDebug("Kill command %v (sid %v)", e.CmdID, e.Info().Sid)
+	e.terminate(e.doneChan())
+}
+
+// killRequested returns true when kill has been called while command was
+// starting
+func (e *ExecOverWS) killRequested() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.killing
+}
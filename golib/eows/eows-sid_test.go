@@ -0,0 +1,26 @@
+// +build !windows
+
+package eows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKillWhileStarting(t *testing.T) {
+	e := New("/bin/sh", []string{"-c", "sleep 5"}, nil, "kill-sid", "kill-starting")
+	e.ExecMode = ExecDirect
+	e.BeforeStart = func(e *ExecOverWS) error {
+		// As KillSid does for a command not yet running
+		e.kill()
+		return nil
+	}
+	start := time.Now()
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	res := e.Wait()
+	if time.Since(start) > 3*time.Second || res.Signal == "" {
+		t.Errorf("exit result = %+v after %v, want killed once running", res, time.Since(start))
+	}
+}
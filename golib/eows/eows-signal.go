@@ -9,8 +9,30 @@ import (
 	"syscall"
 )
 
-// Signal sends a signal to the running command / process
+// Signal sends a signal to the running command / process, it fails when
+// command is not running
 func (e *ExecOverWS) Signal(signal string) error {
+	sig, err := signalByName(signal)
+	if err != nil {
+		return err
+	}
+
+	// Once command has exited, its process group ID may be reused
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.state != StateRunning {
+		return fmt.Errorf("Command %v not running", e.CmdID)
+	}
+	if e.proc == nil {
+		return fmt.Errorf("Cannot retrieve process")
+	}
+
+	e.logDebug("SEND signal %v to proc group %v", sig, e.proc.Pid)
+	return e.signalGroup(sig)
+}
+
+// signalByName returns the signal matching a name (eg. SIGINT or interrupt)
+func signalByName(signal string) (os.Signal, error) {
 	var sig os.Signal
 	switch signal {
 	case "quit", "SIGQUIT":
@@ -36,13 +58,51 @@ func (e *ExecOverWS) Signal(signal string) error {
 	case "user defined signal 2", "SIGUSR2":
 		sig = syscall.SIGUSR2
 	default:
-		return fmt.Errorf("Unsupported signal")
+		return nil, fmt.Errorf("Unsupported signal")
 	}
+	return sig, nil
+}
 
+// signalGroup sends a signal to all processes of the command process group
+func (e *ExecOverWS) signalGroup(sig os.Signal) error {
 	if e.proc == nil {
 		return fmt.Errorf("Cannot retrieve process")
 	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("Unsupported signal")
+	}
+	return syscall.Kill(-e.proc.Pid, s)
+}
 
-	e.logDebug("SEND signal %v to proc %v", sig, e.proc.Pid)
-	return e.proc.Signal(sig)
+// processGone returns true when a signalGroup error means that there is no
+// more process in group
+func processGone(err error) bool {
+	return err == syscall.ESRCH
+}
+
+// signalName returns the name of a signal (eg. SIGKILL)
+func signalName(sig syscall.Signal) string {
+	names := map[syscall.Signal]string{
+		syscall.SIGABRT: "SIGABRT",
+		syscall.SIGBUS:  "SIGBUS",
+		syscall.SIGFPE:  "SIGFPE",
+		syscall.SIGHUP:  "SIGHUP",
+		syscall.SIGILL:  "SIGILL",
+		syscall.SIGINT:  "SIGINT",
+		syscall.SIGKILL: "SIGKILL",
+		syscall.SIGPIPE: "SIGPIPE",
+		syscall.SIGQUIT: "SIGQUIT",
+		syscall.SIGSEGV: "SIGSEGV",
+		syscall.SIGTERM: "SIGTERM",
+		syscall.SIGTRAP: "SIGTRAP",
+		syscall.SIGUSR1: "SIGUSR1",
+		syscall.SIGUSR2: "SIGUSR2",
+		syscall.SIGXCPU: "SIGXCPU",
+		syscall.SIGXFSZ: "SIGXFSZ",
+	}
+	if n, ok := names[sig]; ok {
+		return n
+	}
+	return sig.String()
 }
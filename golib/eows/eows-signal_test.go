@@ -0,0 +1,31 @@
+// +build !windows
+
+package eows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignalState(t *testing.T) {
+	e := New("/bin/sh", []string{"-c", "sleep 5"}, nil, "A", "signal-state")
+	e.ExecMode = ExecDirect
+	if err := e.Signal("SIGTERM"); err == nil {
+		t.Errorf("Signal before Start should fail")
+	}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for e.State() != StateRunning {
+		time.Sleep(time.Millisecond)
+	}
+	if err := e.Signal("SIGTERM"); err != nil {
+		t.Errorf("Signal while running: %v", err)
+	}
+	if res := e.Wait(); res.Signal != "SIGTERM" {
+		t.Errorf("exit result = %+v, want killed by SIGTERM", res)
+	}
+	if err := e.Signal("SIGTERM"); err == nil {
+		t.Errorf("Signal of an exited command should fail")
+	}
+}
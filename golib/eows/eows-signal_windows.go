@@ -1,43 +1,56 @@
+// +build windows
+
 package eows
 
-// +build windows
+import (
+	"fmt"
+	"os"
+)
 
-// Signal sends a signal to the running command / process
+// Signal sends a signal to the running command / process, it fails when
+// command is not running
 func (e *ExecOverWS) Signal(signal string) error {
-	panic("FIXME: Not implemented")
-	/*
-			var sig os.Signal
-			switch signal {
-			case "quit", "SIGQUIT":
-				sig = syscall.SIGQUIT
-			case "terminated", "SIGTERM":
-				sig = syscall.SIGTERM
-			case "interrupt", "SIGINT":
-				sig = syscall.SIGINT
-			case "aborted", "SIGABRT":
-				sig = syscall.SIGABRT
-			case "continued", "SIGCONT":
-				sig = syscall.SIGCONT
-			case "hangup", "SIGHUP":
-				sig = syscall.SIGHUP
-			case "killed", "SIGKILL":
-				sig = syscall.SIGKILL
-			case "stopped (signal)", "SIGSTOP":
-				sig = syscall.SIGSTOP
-			case "stopped", "SIGTSTP":
-				sig = syscall.SIGTSTP
-			case "user defined signal 1", "SIGUSR1":
-				sig = syscall.SIGUSR1
-			case "user defined signal 2", "SIGUSR2":
-				sig = syscall.SIGUSR2
-			default:
-				return fmt.Errorf("Unsupported signal")
-			}
-
-			if e.proc == nil {
-				return fmt.Errorf("Cannot retrieve process")
-			}
-		fmt.Printf("SEND signal %v to proc %v\n", sig, e.proc.Pid)
-		return e.proc.Signal(sig)
-	*/
+	sig, err := signalByName(signal)
+	if err != nil {
+		return err
+	}
+
+	// Once command has exited, its process group ID may be reused
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.state != StateRunning {
+		return fmt.Errorf("Command %v not running", e.CmdID)
+	}
+	if e.proc == nil {
+		return fmt.Errorf("Cannot retrieve process")
+	}
+
+	e.logDebug("SEND signal %v to proc %v", sig, e.proc.Pid)
+	return e.signalGroup(sig)
+}
+
+// signalByName returns the signal matching a name, only interrupt and kill
+// signals are supported on Windows
+func signalByName(signal string) (os.Signal, error) {
+	switch signal {
+	case "interrupt", "SIGINT":
+		return os.Interrupt, nil
+	case "killed", "SIGKILL":
+		return os.Kill, nil
+	}
+	return nil, fmt.Errorf("Unsupported signal")
+}
+
+// processGone returns true when a signalGroup error means that process has
+// already exited
+func processGone(err error) bool {
+	return err == os.ErrProcessDone
+}
+
+// signalGroup sends a signal to the command process (no process group on Windows)
+func (e *ExecOverWS) signalGroup(sig os.Signal) error {
+	if e.proc == nil {
+		return fmt.Errorf("Cannot retrieve process")
+	}
+	return e.proc.Signal(sig)
 }
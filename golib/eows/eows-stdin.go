@@ -0,0 +1,78 @@
+package eows
+
+import (
+	"fmt"
+	"os"
+)
+
+// Control characters sent by SendControl
+const (
+	CtrlC byte = 0x03 // interrupt (SIGINT)
+	CtrlD byte = 0x04 // end of file
+	CtrlZ byte = 0x1a // suspend (SIGTSTP)
+)
+
+// stdin returns command stdin, or an error when command is not running
+func (e *ExecOverWS) stdin() (*os.File, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.state != StateRunning || e.inw == nil {
+		return nil, fmt.Errorf("Command %v not running", e.CmdID)
+	}
+	return e.inw, nil
+}
+
+// Write writes data to command stdin (so ExecOverWS is an io.Writer)
+func (e *ExecOverWS) Write(p []byte) (int, error) {
+	inw, err := e.stdin()
+	if err != nil {
+		return 0, err
+	}
+	return inw.Write(p)
+}
+
+// CloseStdin sends end of file to command: stdin is closed, or Ctrl-D is
+// written in PtyMode (closing pty master would hang up command)
+func (e *ExecOverWS) CloseStdin() error {
+	inw, err := e.stdin()
+	if err != nil {
+		return err
+	}
+	if e.PtyMode {
+		_, err = inw.Write([]byte{CtrlD})
+		return err
+	}
+	return inw.Close()
+}
+
+// SendControl sends a control character to command. In PtyMode character is
+// written to terminal that handles it, else it is emulated: CtrlC sends
+// SIGINT, CtrlZ sends SIGTSTP to process group and CtrlD closes stdin.
+func (e *ExecOverWS) SendControl(c byte) error {
+	inw, err := e.stdin()
+	if err != nil {
+		return err
+	}
+	if e.PtyMode {
+		_, err = inw.Write([]byte{c})
+		return err
+	}
+
+	var name string
+	switch c {
+	case CtrlC:
+		name = "SIGINT"
+	case CtrlZ:
+		name = "SIGTSTP"
+	case CtrlD:
+		return inw.Close()
+	default:
+		_, err = inw.Write([]byte{c})
+		return err
+	}
+	sig, err := signalByName(name)
+	if err != nil {
+		return err
+	}
+	return e.signalGroup(sig)
+}
@@ -0,0 +1,104 @@
+package eows
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ChanMessage is a message exchanged over ChanTransport
+type ChanMessage struct {
+	Event string
+	Data  interface{}
+}
+
+// ChanTransport is an in-memory transport (eg. for tests or to bridge
+// another protocol). Data sent to client is written into Out, messages
+// written into In are dispatched to handlers (Data must be a string for input
+// events and a TerminalSize for resize events) and closing In notifies
+// disconnection.
+type ChanTransport struct {
+	Out chan ChanMessage
+	In  chan ChanMessage
+
+	id       string
+	lock     sync.Mutex
+	handlers map[string]func(data interface{})
+	closeCB  closeHandlers
+	closed   chan struct{}
+	reader   sync.Once
+}
+
+// NewChanTransport creates an in-memory transport with channels of given size
+func NewChanTransport(id string, size int) *ChanTransport {
+	return &ChanTransport{
+		Out:      make(chan ChanMessage, size),
+		In:       make(chan ChanMessage, size),
+		id:       id,
+		handlers: make(map[string]func(data interface{})),
+		closed:   make(chan struct{}),
+	}
+}
+
+// ID returns transport ID
+func (t *ChanTransport) ID() string {
+	return t.id
+}
+
+// Send writes a message into Out, blocking until it is read or In is closed
+func (t *ChanTransport) Send(event string, data interface{}) error {
+	select {
+	case t.Out <- ChanMessage{Event: event, Data: data}:
+		return nil
+	case <-t.closed:
+		return fmt.Errorf("Transport %v closed", t.id)
+	}
+}
+
+// OnInput registers input handler
+func (t *ChanTransport) OnInput(event string, fn func(data string)) error {
+	t.on(event, func(data interface{}) {
+		if in, ok := data.(string); ok {
+			fn(in)
+		}
+	})
+	return nil
+}
+
+// OnResize registers terminal resize handler
+func (t *ChanTransport) OnResize(event string, fn func(size TerminalSize)) error {
+	t.on(event, func(data interface{}) {
+		if size, ok := data.(TerminalSize); ok {
+			fn(size)
+		}
+	})
+	return nil
+}
+
+// OnClose registers a handler called when In is closed
+func (t *ChanTransport) OnClose(fn func()) error {
+	t.closeCB.add(fn)
+	t.reader.Do(func() { go t.readLoop() })
+	return nil
+}
+
+// on registers a message handler and starts reading In
+func (t *ChanTransport) on(event string, fn func(data interface{})) {
+	t.lock.Lock()
+	t.handlers[event] = fn
+	t.lock.Unlock()
+	t.reader.Do(func() { go t.readLoop() })
+}
+
+// readLoop dispatches messages of In to handlers until In is closed
+func (t *ChanTransport) readLoop() {
+	for msg := range t.In {
+		t.lock.Lock()
+		fn := t.handlers[msg.Event]
+		t.lock.Unlock()
+		if fn != nil {
+			fn(msg.Data)
+		}
+	}
+	close(t.closed)
+	t.closeCB.close()
+}
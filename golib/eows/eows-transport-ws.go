@@ -0,0 +1,130 @@
+package eows
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketMessage is the JSON message exchanged over WebSocketTransport
+type WebSocketMessage struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// DefaultWriteTimeout is the max time to write a message on a websocket
+const DefaultWriteTimeout = 10 * time.Second
+
+// WebSocketTransport is the transport over a gorilla websocket connection,
+// each message is a JSON encoded WebSocketMessage
+type WebSocketTransport struct {
+	WriteTimeout time.Duration // max time to write a message (default DefaultWriteTimeout)
+
+	conn     *websocket.Conn
+	id       string
+	wlock    sync.Mutex
+	lock     sync.Mutex
+	handlers map[string]func(data json.RawMessage)
+	closeCB  closeHandlers
+	reader   sync.Once
+}
+
+// NewWebSocketTransport creates a transport over a websocket connection.
+// Connection is read once a handler is registered.
+func NewWebSocketTransport(conn *websocket.Conn, id string) *WebSocketTransport {
+	return &WebSocketTransport{
+		conn:     conn,
+		id:       id,
+		handlers: make(map[string]func(data json.RawMessage)),
+	}
+}
+
+// ID returns connection ID
+func (t *WebSocketTransport) ID() string {
+	return t.id
+}
+
+// Send writes an event message on connection
+func (t *WebSocketTransport) Send(event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	tmo := t.WriteTimeout
+	if tmo <= 0 {
+		tmo = DefaultWriteTimeout
+	}
+
+	t.wlock.Lock()
+	defer t.wlock.Unlock()
+	// A stalled connection must not block command output forever
+	t.conn.SetWriteDeadline(time.Now().Add(tmo))
+	return t.conn.WriteJSON(WebSocketMessage{Event: event, Data: b})
+}
+
+// OnInput registers input handler, message data must be a JSON string
+func (t *WebSocketTransport) OnInput(event string, fn func(data string)) error {
+	t.on(event, func(data json.RawMessage) {
+		var in string
+		if err := json.Unmarshal(data, &in); err == nil {
+			fn(in)
+		}
+	})
+	return nil
+}
+
+// OnResize registers terminal resize handler, message data must be a JSON
+// encoded TerminalSize
+func (t *WebSocketTransport) OnResize(event string, fn func(size TerminalSize)) error {
+	t.on(event, func(data json.RawMessage) {
+		var size TerminalSize
+		if err := json.Unmarshal(data, &size); err == nil {
+			fn(size)
+		}
+	})
+	return nil
+}
+
+// OnClose registers a handler called when connection is closed
+func (t *WebSocketTransport) OnClose(fn func()) error {
+	t.closeCB.add(fn)
+	t.reader.Do(func() { go t.readLoop() })
+	return nil
+}
+
+// Close closes connection
+func (t *WebSocketTransport) Close() error {
+	return t.conn.Close()
+}
+
+// on registers a message handler and starts reading connection
+func (t *WebSocketTransport) on(event string, fn func(data json.RawMessage)) {
+	t.lock.Lock()
+	t.handlers[event] = fn
+	t.lock.Unlock()
+	t.reader.Do(func() { go t.readLoop() })
+}
+
+// readLoop dispatches received messages to handlers until connection is
+// closed or broken, connection is then closed
+func (t *WebSocketTransport) readLoop() {
+	defer t.closeCB.close()
+	defer t.conn.Close()
+	for {
+		var msg WebSocketMessage
+		if err := t.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				continue
+			}
+			return
+		}
+		t.lock.Lock()
+		fn := t.handlers[msg.Event]
+		t.lock.Unlock()
+		if fn != nil {
+			fn(msg.Data)
+		}
+	}
+}
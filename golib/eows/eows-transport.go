@@ -0,0 +1,110 @@
+package eows
+
+import (
+	"sync"
+
+	"github.com/googollee/go-socket.io"
+)
+
+// Transport is the connection used to exchange command data with a client
+type Transport interface {
+	ID() string                                              // client ID
+	Send(event string, data interface{}) error               // send data (eg. output) to client
+	OnInput(event string, fn func(data string)) error        // register input handler
+	OnResize(event string, fn func(size TerminalSize)) error // register terminal resize handler
+	OnClose(fn func()) error                                 // register disconnection handler
+}
+
+// closeHandlers is a list of disconnection handlers called once
+type closeHandlers struct {
+	lock   sync.Mutex
+	fns    []func()
+	closed bool
+}
+
+// add registers a handler, called immediately when already closed
+func (ch *closeHandlers) add(fn func()) {
+	ch.lock.Lock()
+	closed := ch.closed
+	if !closed {
+		ch.fns = append(ch.fns, fn)
+	}
+	ch.lock.Unlock()
+	if closed {
+		fn()
+	}
+}
+
+// close calls registered handlers (only first time)
+func (ch *closeHandlers) close() {
+	ch.lock.Lock()
+	fns := ch.fns
+	ch.fns = nil
+	ch.closed = true
+	ch.lock.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// SocketIOTransport is the transport over a googollee socket.io socket
+type SocketIOTransport struct {
+	so      *socketio.Socket
+	closeCB closeHandlers
+}
+
+// NewSocketIOTransport creates a transport over a socket.io socket
+func NewSocketIOTransport(so *socketio.Socket) *SocketIOTransport {
+	return &SocketIOTransport{so: so}
+}
+
+// ID returns socket ID
+func (t *SocketIOTransport) ID() string {
+	return (*t.so).Id()
+}
+
+// Send emits an event on socket
+func (t *SocketIOTransport) Send(event string, data interface{}) error {
+	return (*t.so).Emit(event, data)
+}
+
+// OnInput registers input handler on socket
+func (t *SocketIOTransport) OnInput(event string, fn func(data string)) error {
+	return (*t.so).On(event, fn)
+}
+
+// OnResize registers terminal resize handler on socket
+func (t *SocketIOTransport) OnResize(event string, fn func(size TerminalSize)) error {
+	return (*t.so).On(event, fn)
+}
+
+// OnClose registers a disconnection handler. Socket disconnection event is
+// not hooked (a socket only has one handler, usually owned by server), so
+// SocketIODisconnected (or Close) must be called from server disconnection
+// handler.
+func (t *SocketIOTransport) OnClose(fn func()) error {
+	t.closeCB.add(fn)
+	return nil
+}
+
+// Close notifies socket disconnection to registered handlers
+func (t *SocketIOTransport) Close() {
+	t.closeCB.close()
+}
+
+// SocketIODisconnected notifies the disconnection of socket sid to the
+// commands using it (see SocketIOTransport.OnClose), so that they are
+// detached. It must be called from server socket.io disconnection
+// handler, eg.
+//
+//	so.On("disconnection", func() { eows.SocketIODisconnected(so.Id()) })
+func SocketIODisconnected(sid string) {
+	for _, e := range cmdIDMap.list() {
+		e.lock.Lock()
+		t, ok := e.Transport.(*SocketIOTransport)
+		e.lock.Unlock()
+		if ok && t.ID() == sid {
+			t.Close()
+		}
+	}
+}
@@ -1,10 +1,14 @@
 // Package eows is used to Execute commands Over WebSocket
+//
+// It requires Go 1.16 or later. Limits.Cgroup requires Go 1.20 or later and
+// Linux 5.7 or later, that starts command directly in its cgroup (clone3).
 package eows
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -17,8 +21,14 @@ type OnInputCB func(e *ExecOverWS, stdin string) (string, error)
 // EmitOutputCB is the function callback used to emit data
 type EmitOutputCB func(e *ExecOverWS, stdout, stderr string)
 
-// EmitExitCB is the function callback used to emit exit proc code
-type EmitExitCB func(e *ExecOverWS, code int, err error)
+// EmitRawOutputCB is the function callback used to emit raw (binary) data
+type EmitRawOutputCB func(e *ExecOverWS, stdout, stderr []byte)
+
+// EmitOutputEventCB is the function callback used to emit output events
+type EmitOutputEventCB func(e *ExecOverWS, ev OutputEvent)
+
+// EmitExitCB is the function callback used to emit exit proc result
+type EmitExitCB func(e *ExecOverWS, res ExitResult)
 
 // SplitType Type of spliting method to tokenize stdout/stderr
 type SplitType uint8
@@ -30,6 +40,33 @@ const (
 	SplitChar
 )
 
+// LongLinePolicy Type of policy applied to lines longer than OutMaxLineSize
+type LongLinePolicy uint8
+
+const (
+	// LongLineSplit Split long line in several lines
+	LongLineSplit LongLinePolicy = iota
+	// LongLineTruncate Truncate long line and add LongLineMarker
+	LongLineTruncate
+)
+
+// TerminalSize is the window size of the pseudo-terminal used in PtyMode
+type TerminalSize struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+}
+
+// Credential is the user and groups identity used to run the command
+type Credential struct {
+	Uid    uint32   // user ID
+	Gid    uint32   // group ID
+	Groups []uint32 // supplementary group IDs
+}
+
+// outputDrainDelay is the max time to read remaining output once command
+// has been terminated
+const outputDrainDelay = 1 * time.Second
+
 // Inspired by :
 // https://github.com/gorilla/websocket/blob/master/examples/command/main.go
 
@@ -37,28 +74,88 @@ const (
 type ExecOverWS struct {
 	Cmd      string           // command name to execute
 	Args     []string         // command arguments
-	SocketIO *socketio.Socket // websocket
+	SocketIO *socketio.Socket // websocket (see Transport)
 	Sid      string           // websocket ID
 	CmdID    string           // command ID
 
 	// Optional fields
-	Env            []string                // command environment variables
+	Transport      Transport               // client connection (default socket.io transport over SocketIO)
+	Env            []string                // command environment variables (see environ for override order)
+	EnvPolicy      EnvPolicy               // server environment inherited by command (default EnvInheritAll)
+	EnvInherit     []string                // server variables inherited in EnvInheritList policy
+	EnvExpand      bool                    // resolve ${VAR} in Env values from command environment
 	CmdExecTimeout int                     // command execution time timeout
+	CmdIdleTimeout int                     // timeout in seconds without any output (0 to disable)
+	KillSequence   []KillStep              // signals sent to stop command (default DefaultKillSequence)
 	Log            *logrus.Logger          // logger (nil if disabled)
 	InputEvent     string                  // websocket input event name
+	OutputEvent    string                  // transport output event name, used when no output callback is set
+	ExitEvent      string                  // transport exit event name, used when ExitCB is not set
+	ResizeEvent    string                  // websocket terminal resize event name (PtyMode only)
 	InputCB        OnInputCB               // stdin callback
 	OutputCB       EmitOutputCB            // stdout/stderr callback
+	RawOutputCB    EmitRawOutputCB         // raw stdout/stderr callback, replaces OutputCB when set
+	OutputEventCB  EmitOutputEventCB       // output events callback, replaces OutputCB when set
 	ExitCB         EmitExitCB              // exit proc callback
+	BeforeStart    BeforeStartCB           // hook called before spawn, may modify command or abort Start
+	AfterStart     AfterStartCB            // hook called once command has been spawned
+	AfterExit      AfterExitCB             // hook called once exit result has been emitted
 	UserData       *map[string]interface{} // user data passed to callbacks
 	OutSplit       SplitType               // split method to tokenize stdout/stderr
+	OutMaxLineSize int                     // max line length in SplitLine mode (default DefaultOutMaxLineSize)
+	OutLongLine    LongLinePolicy          // policy applied to longer lines (default LongLineSplit)
+	PtyMode        bool                    // run command under a pseudo-terminal
+	OutBufferSize  int                     // size in bytes of output history buffer (0 to disable)
+	OutBatchSize   int                     // max bytes coalesced in one OutputCB call (0 to disable)
+	OutBatchDelay  time.Duration           // max delay of coalesced output (default DefaultOutBatchDelay)
+	OutPolicy      OutputPolicy            // policy when OutputCB can't keep up (default OutputBlock)
+	OutQueueSize   int                     // max bytes waiting for OutputCB (default DefaultOutQueueSize)
+	ExecMode       ExecMode                // method used to execute command (default ExecBash)
+	Shell          string                  // shell path used in ExecShell mode
+	ShellFlags     []string                // shell flags used in ExecShell mode (default -c)
+	WorkingDir     string                  // command working directory
+	Umask          *os.FileMode            // command file mode creation mask (nil to inherit, set with /bin/sh)
+	Credential     *Credential             // user and groups running command (nil to inherit)
+	Limits         *Limits                 // command resource limits (nil for no limit)
+	Record         *Record                 // record output in a file (nil to disable)
 
 	// Private fields
-	proc *os.Process
+	proc       *os.Process
+	ptm        *os.File
+	ptySize    TerminalSize
+	lock       sync.Mutex
+	state      CmdState
+	pid        int
+	startTime  time.Time
+	exitTime   time.Time
+	done       chan struct{}
+	started    bool
+	killing    bool
+	inw        *os.File
+	outCond    *sync.Cond
+	callSeq    uint64
+	replaying  bool
+	exitKept   bool
+	keepTimer  *time.Timer
+	detached   bool
+	pending    outputRing
+	history    outputRing
+	outSeq     uint64
+	offsets    [3]int64
+	outBytes   int64
+	limitErr   error
+	lastOutput time.Time
+	outq       *outQueue
+	cgroup     *os.File
+	cgroupPids bool
+	rec        *recorder
+	ctx        context.Context
+	exitRes    ExitResult
 }
 
-var cmdIDMap = make(map[string]*ExecOverWS)
-
 // New creates a new instace of eows
+// Command is registered (and so available through GetEows) by Start, that
+// returns an error when command ID is already used by another command.
 func New(cmd string, args []string, so *socketio.Socket, soID, cmdID string) *ExecOverWS {
 
 	e := &ExecOverWS{
@@ -71,99 +168,183 @@ func New(cmd string, args []string, so *socketio.Socket, soID, cmdID string) *Ex
 		OutSplit:       SplitChar, // default split by character
 	}
 
-	cmdIDMap[cmdID] = e
-
 	return e
 }
 
-// GetEows gets ExecOverWS object from command ID
-func GetEows(cmdID string) *ExecOverWS {
-	if _, ok := cmdIDMap[cmdID]; !ok {
-		return nil
-	}
-	return cmdIDMap[cmdID]
-}
-
 // Start executes the command and redirect stdout/stderr into a WebSocket
 func (e *ExecOverWS) Start() error {
+	return e.StartContext(context.Background())
+}
+
+// StartContext is like Start, but the command is stopped (using KillSequence)
+// when ctx is done, or not started at all when ctx is already done
+func (e *ExecOverWS) StartContext(ctx context.Context) error {
 	var err error
 	var outr, outw, errr, errw, inr, inw *os.File
+	var progPath string
+	var progArgs []string
+	var canceled bool
+
+	if err = e.begin(); err != nil {
+		return err
+	}
 
-	bashArgs := []string{"/bin/bash", "-c", e.Cmd + " " + strings.Join(e.Args, " ")}
+	procAttr := &os.ProcAttr{}
+	e.ctx = ctx
+
+	if e.BeforeStart != nil {
+		if err = e.BeforeStart(e); err != nil {
+			goto exitErr
+		}
+	}
+	procAttr.Dir = e.WorkingDir
+	if err = cmdIDMap.add(e); err != nil {
+		goto exitErr
+	}
+	if e.Transport == nil && e.SocketIO != nil {
+		e.Transport = NewSocketIOTransport(e.SocketIO)
+	}
+	e.setState(StateStarting)
+	e.history.maxSize = e.OutBufferSize
+
+	if procAttr.Env, err = e.environ(); err != nil {
+		goto exitErr
+	}
+	if procAttr.Sys, err = e.sysProcAttr(); err != nil {
+		goto exitErr
+	}
+	// Cgroup first, ulimit prelude depends on pids controller availability
+	if err = e.cgroupCreate(procAttr.Sys); err != nil {
+		goto exitErr
+	}
+	if progPath, progArgs, err = e.cmdArgs(procAttr.Env); err != nil {
+		goto exitErr
+	}
+	if err = e.recordOpen(); err != nil {
+		goto exitErr
+	}
 
 	// no timeout == 1 year
 	if e.CmdExecTimeout == -1 {
 		e.CmdExecTimeout = 365 * 24 * 60 * 60
 	}
 
+	// Context may have been done while preparing command
+	if ctx.Err() != nil {
+		canceled = true
+		err = fmt.Errorf("Command %v canceled before start: %v", e.CmdID, ctx.Err())
+		goto exitErr
+	}
+
+	// Pseudo-terminal: stdin, stdout and stderr all go through pty master
+	if e.PtyMode {
+		var ptm *os.File
+		if ptm, err = e.startPty(progPath, progArgs, procAttr); err != nil {
+			goto exitErr
+		}
+		outr, inw = ptm, ptm
+		goto started
+	}
+
 	// Create pipes
 	outr, outw, err = os.Pipe()
 	if err != nil {
-		err = fmt.Errorf("Pipe stdout error: " + err.Error())
+		err = fmt.Errorf("Pipe stdout error: %v", err)
 		goto exitErr
 	}
 
 	errr, errw, err = os.Pipe()
 	if err != nil {
-		err = fmt.Errorf("Pipe stderr error: " + err.Error())
+		err = fmt.Errorf("Pipe stderr error: %v", err)
 		goto exitErr
 	}
 
 	inr, inw, err = os.Pipe()
 	if err != nil {
-		err = fmt.Errorf("Pipe stdin error: " + err.Error())
+		err = fmt.Errorf("Pipe stdin error: %v", err)
 		goto exitErr
 	}
 
-	e.proc, err = os.StartProcess("/bin/bash", bashArgs, &os.ProcAttr{
-		Files: []*os.File{inr, outw, errw},
-		Env:   append(os.Environ(), e.Env...),
-	})
+	procAttr.Files = []*os.File{inr, outw, errw}
+	e.proc, err = os.StartProcess(progPath, progArgs, procAttr)
 	if err != nil {
-		err = fmt.Errorf("Process start error: " + err.Error())
+		err = fmt.Errorf("Process start error: %v", err)
 		goto exitErr
 	}
 
+	// Child ends are only used by the command, so that EOF is read on
+	// stdout/stderr once command (and its children) exited
+	inr.Close()
+	outw.Close()
+	errw.Close()
+
+started:
+	e.inw = inw
+	e.setState(StateRunning)
+	if e.killRequested() {
+		go e.kill()
+	}
+	if e.AfterStart != nil {
+		e.AfterStart(e, e.proc.Pid)
+	}
+
 	go func() {
-		defer outr.Close()
-		defer outw.Close()
-		defer errr.Close()
-		defer errw.Close()
-		defer inr.Close()
 		defer inw.Close()
 
+		e.startOutputQueue()
+
 		stdoutDone := make(chan struct{})
+		stderrDone := make(chan struct{})
+		pumpsDone := make(chan struct{})
 		go e.cmdPumpStdout(outr, stdoutDone)
-		go e.cmdPumpStderr(errr)
+		if errr != nil {
+			go e.cmdPumpStderr(errr, stderrDone)
+		} else {
+			close(stderrDone)
+		}
+		go func() {
+			<-stdoutDone
+			<-stderrDone
+			close(pumpsDone)
+		}()
 
 		// Blocking function that poll input or wait for end of process
-		e.cmdPumpStdin(inw)
-
-		// Some commands will exit when stdin is closed.
-		inw.Close()
-
-		defer outr.Close()
-
-		if status, err := e.proc.Wait(); err == nil {
-			// Other commands need a bonk on the head.
-			if !status.Exited() {
-				if err := e.proc.Signal(os.Interrupt); err != nil {
-					e.logError("Proc interrupt:", err)
-				}
-
-				select {
-				case <-stdoutDone:
-				case <-time.After(time.Second):
-					// A bigger bonk on the head.
-					if err := e.proc.Signal(os.Kill); err != nil {
-						e.logError("Proc term:", err)
-					}
-					<-stdoutDone
-				}
-			}
+		res := e.cmdPumpStdin(inw)
+		e.setState(StateExiting)
+
+		// Some commands will exit when stdin is closed (pty master is
+		// closed once all output has been read).
+		if !e.PtyMode {
+			inw.Close()
+		}
+
+		// Other commands (or children left in process group on timeout)
+		// need a bonk on the head.
+		e.terminate(pumpsDone)
+
+		// Output may still be held open by a process out of group
+		select {
+		case <-pumpsDone:
+		case <-time.After(outputDrainDelay):
+			outr.Close()
+			errr.Close()
+			<-pumpsDone
+		}
+		outr.Close()
+		errr.Close()
+		e.stopOutputQueue()
+
+		// All output has been emitted, exit callback comes last
+		e.emitExit(res)
+		e.recordClose()
+		if e.AfterExit != nil {
+			e.AfterExit(e, res, e.OutputStats())
 		}
 
-		delete(cmdIDMap, e.CmdID)
+		e.cgroupRemove()
+		e.setState(StateExited)
+		e.unregister()
+		close(e.done)
 	}()
 
 	return nil
@@ -172,9 +353,49 @@ exitErr:
 	for _, pf := range []*os.File{outr, outw, errr, errw, inr, inw} {
 		pf.Close()
 	}
+	e.exitRes = ExitResult{Code: -1, Canceled: canceled, Err: err, Error: err.Error()}
+	e.recordClose()
+	e.cgroupRemove()
+	e.setState(StateExited)
+	cmdIDMap.remove(e)
+	close(e.done)
 	return err
 }
 
+// begin marks command as started, a command can only be started once
+func (e *ExecOverWS) begin() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.started {
+		return fmt.Errorf("Command %v already started", e.CmdID)
+	}
+	e.started = true
+	if e.done == nil {
+		e.done = make(chan struct{})
+	}
+	return nil
+}
+
+// doneChan returns the channel closed once command has exited
+func (e *ExecOverWS) doneChan() chan struct{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.done == nil {
+		e.done = make(chan struct{})
+	}
+	return e.done
+}
+
+// Wait blocks until the command started by Start has exited and all its
+// output has been emitted (or kept for Reattach), then returns its exit result
+func (e *ExecOverWS) Wait() ExitResult {
+	<-e.doneChan()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.exitRes
+}
+
 func (e *ExecOverWS) logDebug(format string, a ...interface{}) {
 	if e.Log != nil {
 		e.Log.Debugf(format, a)
@@ -0,0 +1,86 @@
+// +build !windows
+
+package eows
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestOutputBeforeExit(t *testing.T) {
+	const lines = 2000
+
+	for _, pty := range []bool{false, true} {
+		var lock sync.Mutex
+		count := 0
+		afterExit := 0
+		exited := false
+		last := ""
+
+		e := New("/bin/sh", []string{"-c", fmt.Sprintf("seq 1 %d; echo err >&2; exit 3", lines)},
+			nil, "A", fmt.Sprintf("order-%v", pty))
+		e.ExecMode = ExecDirect
+		e.OutSplit = SplitLine
+		e.PtyMode = pty
+		e.OutputCB = func(e *ExecOverWS, stdout, stderr string) {
+			lock.Lock()
+			defer lock.Unlock()
+			if exited {
+				afterExit++
+			}
+			count++
+			if stdout != "" {
+				last = stdout
+			}
+		}
+		e.ExitCB = func(e *ExecOverWS, res ExitResult) {
+			lock.Lock()
+			defer lock.Unlock()
+			exited = true
+		}
+		if err := e.Start(); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		res := e.Wait()
+
+		lock.Lock()
+		if res.Code != 3 {
+			t.Errorf("pty %v: exit code = %d, want 3", pty, res.Code)
+		}
+		if !exited || afterExit != 0 {
+			t.Errorf("pty %v: exit callback not last (exited %v, %d after)", pty, exited, afterExit)
+		}
+		// In PtyMode, stderr goes to terminal too
+		if count != lines+1 {
+			t.Errorf("pty %v: %d output callbacks, want %d", pty, count, lines+1)
+		}
+		if last != fmt.Sprint(lines) && last != "err" {
+			t.Errorf("pty %v: last output = %q", pty, last)
+		}
+		lock.Unlock()
+	}
+}
+
+func TestStartContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	started := false
+	e := New("/bin/sh", []string{"-c", "true"}, nil, "A", "ctx-done")
+	e.ExecMode = ExecDirect
+	e.AfterStart = func(e *ExecOverWS, pid int) {
+		started = true
+	}
+	if err := e.StartContext(ctx); err == nil {
+		t.Fatalf("StartContext with a done context should fail")
+	}
+	res := e.Wait()
+	if started || !res.Canceled || res.Err == nil {
+		t.Errorf("started %v, exit result = %+v, want canceled before start", started, res)
+	}
+	if e.State() != StateExited || GetEows(e.CmdID) != nil {
+		t.Errorf("canceled command should be exited and unregistered")
+	}
+}